@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"strconv"
+
+	"github.com/xiphoid24/csv/internal/tags"
+)
+
+// csvMethods writes MarshalCSVRow and UnmarshalCSVRow for a struct type,
+// following the column rules of the csv package's EncodeRow and DecodeRow.
+func (g *generator) csvMethods(w *bytes.Buffer, name string) error {
+	var enc, dec bytes.Buffer
+	if err := g.csvFields(&enc, &dec, name, "v", "", 0); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n// MarshalCSVRow implements csv.RowMarshaler.\n")
+	fmt.Fprintf(w, "func (v %s) MarshalCSVRow() ([]string, error) {\n", name)
+	fmt.Fprintf(w, "var cells []string\n%s", enc.Bytes())
+	fmt.Fprintf(w, "return cells, nil\n}\n")
+
+	fmt.Fprintf(w, "\n// UnmarshalCSVRow implements csv.RowUnmarshaler.\n")
+	fmt.Fprintf(w, "func (v *%s) UnmarshalCSVRow(header map[string]int, row []string) (bool, error) {\n", name)
+	fmt.Fprintf(w, `cell := func(path, label string) (string, bool) {
+	i, ok := header[path]
+	if !ok && label != "" {
+		i, ok = header[label]
+	}
+	if !ok {
+		return "", false
+	}
+	if i >= len(row) {
+		return "", true
+	}
+	return row[i], true
+}
+filled := false
+`)
+	fmt.Fprintf(w, "%sreturn filled, nil\n}\n", dec.Bytes())
+	return nil
+}
+
+// csvFields writes the statements encoding and decoding the fields of
+// strct, reached through access, whose column paths start with start.
+func (g *generator) csvFields(enc, dec *bytes.Buffer, strct, access, start string, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("%s: structs nested too deeply", strct)
+	}
+	for _, f := range g.fields(strct) {
+		tag, opts := tags.Parse(f.tag.Get("csv"))
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = f.name
+		}
+		if f.typ.kind == kindSkip || f.typ.kind == kindPtr {
+			continue
+		}
+		if f.err != nil {
+			return fmt.Errorf("%s.%s: %v", strct, f.name, f.err)
+		}
+		if !ast.IsExported(f.name) {
+			return fmt.Errorf("%s.%s: unexported fields need a `csv:\"-\"` tag", strct, f.name)
+		}
+		for _, key := range []string{"locale", "currency", "percent"} {
+			if _, ok := opts[key]; ok {
+				return fmt.Errorf("%s.%s: the %s option is not supported", strct, f.name, key)
+			}
+		}
+
+		x := access + "." + f.name
+		if f.typ.kind == kindStruct {
+			if err := g.csvFields(enc, dec, f.typ.strct, x, start+tag+".", depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		path, label := start+tag, f.tag.Get("csvlabel")
+		if err := g.csvEncode(enc, f.typ, x, opts); err != nil {
+			return fmt.Errorf("%s.%s: %v", strct, f.name, err)
+		}
+		if err := g.csvDecode(dec, f.typ, x, path, label, opts); err != nil {
+			return fmt.Errorf("%s.%s: %v", strct, f.name, err)
+		}
+	}
+	return nil
+}
+
+// csvEncode writes statements appending the cell of x to cells, like the
+// csv package's formatValue with default encoder settings.
+func (g *generator) csvEncode(w *bytes.Buffer, ft fieldType, x string, opts tags.Options) error {
+	switch ft.kind {
+	case kindNull:
+		fmt.Fprintf(w, "if !%s.Valid {\ncells = append(cells, \"\")\n} else {\n", x)
+		if err := g.csvEncode(w, *ft.inner, x+"."+ft.null, opts); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "}\n")
+	case kindString:
+		fmt.Fprintf(w, "cells = append(cells, %s)\n", unconvert(ft, "string", x))
+	case kindInt, kindUint:
+		base, err := opts.Base(10)
+		if err != nil {
+			return err
+		}
+		g.use("strconv")
+		if ft.kind == kindInt {
+			fmt.Fprintf(w, "cells = append(cells, strconv.FormatInt(%s, %d))\n", unconvert(ft, "int64", x), base)
+		} else {
+			fmt.Fprintf(w, "cells = append(cells, strconv.FormatUint(%s, %d))\n", unconvert(ft, "uint64", x), base)
+		}
+	case kindFloat:
+		format, prec := byte(0), -1
+		if f, ok := opts["format"]; ok {
+			if len(f) != 1 {
+				return fmt.Errorf("invalid format option %q", f)
+			}
+			format = f[0]
+		}
+		if _, ok := opts["prec"]; ok {
+			var err error
+			if prec, err = opts.Int("prec", prec); err != nil {
+				return err
+			}
+			if format == 0 {
+				format = 'f'
+			}
+		}
+		switch {
+		case format == 0 && ft.stringer:
+			g.use("fmt")
+			fmt.Fprintf(w, "cells = append(cells, fmt.Sprint(%s))\n", x)
+		case format == 0:
+			g.use("strconv")
+			fmt.Fprintf(w, "cells = append(cells, strconv.FormatFloat(%s, 'g', -1, %d))\n", unconvert(ft, "float64", x), ft.bits)
+		default:
+			g.use("strconv")
+			fmt.Fprintf(w, "cells = append(cells, strconv.FormatFloat(%s, %s, %d, %d))\n", unconvert(ft, "float64", x), strconv.QuoteRune(rune(format)), prec, ft.bits)
+		}
+	case kindBool:
+		t, f := "true", "false"
+		if s, ok := opts["true"]; ok {
+			t = s
+		}
+		if s, ok := opts["false"]; ok {
+			f = s
+		}
+		fmt.Fprintf(w, "if %s {\ncells = append(cells, %q)\n} else {\ncells = append(cells, %q)\n}\n", x, t, f)
+	case kindTime:
+		fmt.Fprintf(w, "cells = append(cells, %s.Format(%s))\n", x, g.layout(opts))
+	}
+	return nil
+}
+
+// csvDecode writes a block setting x from its cell, like the csv package's
+// DecodeRow and setValue with default decoder settings.
+func (g *generator) csvDecode(w *bytes.Buffer, ft fieldType, x, path, label string, opts tags.Options) error {
+	if ft.kind == kindNull {
+		g.use("database/sql")
+		fmt.Fprintf(w, "if s, ok := cell(%q, %q); ok {\nif s == \"\" {\n%s = %s{}\n} else {\nfilled = true\n", path, label, x, ft.name)
+		if err := g.csvParse(w, *ft.inner, x+"."+ft.null, path, opts); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s.Valid = true\n}\n}\n", x)
+		return nil
+	}
+	fmt.Fprintf(w, "if s, ok := cell(%q, %q); ok && s != \"\" {\nfilled = true\n", path, label)
+	if err := g.csvParse(w, ft, x, path, opts); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "}\n")
+	return nil
+}
+
+// csvParse writes statements parsing s into x.
+func (g *generator) csvParse(w *bytes.Buffer, ft fieldType, x, path string, opts tags.Options) error {
+	fail := func(msg string) string {
+		g.use("errors")
+		g.use(csvImport)
+		return fmt.Sprintf("return filled, &csv.ValueError{Column: %q, Value: s, Err: errors.New(%q)}\n", path, msg)
+	}
+	rangeErr := func(v string) string {
+		g.use("strconv")
+		g.use("reflect")
+		g.use(csvImport)
+		return fmt.Sprintf("if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {\n"+
+			"return filled, &csv.RangeError{Column: %q, Value: s, Type: reflect.TypeOf(%s)}\n}\n", path, v)
+	}
+
+	switch ft.kind {
+	case kindString:
+		fmt.Fprintf(w, "%s = %s\n", x, convert(ft, "string", "s"))
+	case kindInt, kindUint:
+		base, err := opts.Base(10)
+		if err != nil {
+			return err
+		}
+		parse, conv := "ParseInt", "int64"
+		if ft.kind == kindUint {
+			parse, conv = "ParseUint", "uint64"
+		}
+		fmt.Fprintf(w, "n, err := strconv.%s(s, %d, %s)\nif err != nil {\n%s%s}\n%s = %s\n",
+			parse, base, bitsArg(ft), rangeErr(x), fail("must be a number"), x, convert(ft, conv, "n"))
+		return g.bounds(w, ft, x, path, "filled, ", opts)
+	case kindFloat:
+		fmt.Fprintf(w, "f, err := strconv.ParseFloat(s, %d)\nif err != nil {\n%s%s}\n%s = %s\n",
+			ft.bits, rangeErr(x), fail("must be a number"), x, convert(ft, "float64", "f"))
+		return g.bounds(w, ft, x, path, "filled, ", opts)
+	case kindBool:
+		t, hasT := opts["true"]
+		f, hasF := opts["false"]
+		g.use("strconv")
+		fmt.Fprintf(w, "switch {\n")
+		if hasT && t != "" {
+			g.use("strings")
+			fmt.Fprintf(w, "case strings.EqualFold(s, %q):\n%s = true\n", t, x)
+		}
+		if hasF && f != "" {
+			g.use("strings")
+			fmt.Fprintf(w, "case strings.EqualFold(s, %q):\n%s = false\n", f, x)
+		}
+		fmt.Fprintf(w, "default:\nb, err := strconv.ParseBool(s)\nif err != nil {\n%s}\n%s = %s\n}\n",
+			fail("must be true or false"), x, convert(ft, "bool", "b"))
+	case kindTime:
+		fmt.Fprintf(w, "t, err := time.Parse(%s, s)\nif err != nil {\n%s}\n%s = t\n", g.layout(opts), fail("must be a time"), x)
+	}
+	return nil
+}
+
+// bounds writes the checks of the min and max tag options. ret prefixes
+// the error in the generated return statements.
+func (g *generator) bounds(w *bytes.Buffer, ft fieldType, x, path, ret string, opts tags.Options) error {
+	for _, key := range []string{"min", "max"} {
+		bound, ok := opts[key]
+		if !ok {
+			continue
+		}
+		var lit, v string
+		switch ft.kind {
+		case kindInt:
+			b, err := strconv.ParseInt(bound, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s option %q", key, bound)
+			}
+			lit, v = strconv.FormatInt(b, 10), unconvert(ft, "int64", x)
+		case kindUint:
+			b, err := strconv.ParseUint(bound, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s option %q", key, bound)
+			}
+			lit, v = strconv.FormatUint(b, 10), unconvert(ft, "uint64", x)
+		case kindFloat:
+			b, err := strconv.ParseFloat(bound, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s option %q", key, bound)
+			}
+			lit, v = strconv.FormatFloat(b, 'g', -1, 64), unconvert(ft, "float64", x)
+		}
+		op, field := "<", "Min"
+		if key == "max" {
+			op, field = ">", "Max"
+		}
+		cond := fmt.Sprintf("%s %s %s", v, op, lit)
+		if ft.kind == kindFloat {
+			// NaN is outside any bound
+			g.use("math")
+			cond = fmt.Sprintf("math.IsNaN(%s) || %s", v, cond)
+		}
+		g.use("reflect")
+		g.use(csvImport)
+		fmt.Fprintf(w, "if %s {\nreturn %s&csv.RangeError{Column: %q, Value: s, Type: reflect.TypeOf(%s), %s: %q}\n}\n",
+			cond, ret, path, x, field, bound)
+	}
+	return nil
+}
+
+func (g *generator) layout(opts tags.Options) string {
+	g.use("time")
+	if layout, ok := opts["layout"]; ok {
+		return strconv.Quote(layout)
+	}
+	return "time.RFC3339"
+}
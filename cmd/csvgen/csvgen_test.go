@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden file")
+
+// TestGolden regenerates the methods of package internal/gentest, whose
+// tests check them against reflection, and compares them with the
+// checked-in file.
+func TestGolden(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	golden := filepath.Join(dir, "gen_csv.go")
+
+	g, err := newGenerator(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := g.generate([]string{"Order", "Contact"}, "-type Order,Contact -o gen_csv.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+		for i := 0; i < len(gotLines) && i < len(wantLines); i++ {
+			if gotLines[i] != wantLines[i] {
+				t.Fatalf("%s differs at line %d, run go test -update to rewrite it\ngot:  %s\nwant: %s", golden, i+1, gotLines[i], wantLines[i])
+			}
+		}
+		t.Fatalf("%s differs in length, run go test -update to rewrite it", golden)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		typ  string
+		want string
+	}{
+		{"type T struct{ A int `csv:\"a,locale=de\"` }", "T", "T.A: the locale option is not supported"},
+		{"type T struct{ a int }", "T", "T.a: unexported fields need a `csv:\"-\"` tag"},
+		{"type T struct{ A int `csv:\"a,base=x\"` }", "T", `T.A: invalid base option "x"`},
+		{"type T struct{ A int `csv:\"a,min=x\"` }", "T", `T.A: invalid min option "x"`},
+		{"type T int", "T", "T is not a struct type"},
+		{"type T struct{}", "U", "type U not found"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "t.go"), []byte("package p\n\n"+tt.src+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		g, err := newGenerator(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = g.generate([]string{tt.typ}, "")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error = %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
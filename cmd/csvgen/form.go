@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+
+	"github.com/xiphoid24/csv/internal/tags"
+)
+
+// formMethods writes MarshalCSVForm and UnmarshalCSVForm for a struct type,
+// following the key rules of the form package's EncodeRelationRow and
+// DecodeRelationRow.
+func (g *generator) formMethods(w *bytes.Buffer, name string) error {
+	var enc, dec bytes.Buffer
+	if err := g.formFields(&enc, &dec, name, "v", "v", "", "", 0); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n// MarshalCSVForm implements form.RowMarshaler.\n")
+	fmt.Fprintf(w, "func (v %s) MarshalCSVForm(set func(key, val string) error) error {\n", name)
+	fmt.Fprintf(w, "%sreturn nil\n}\n", enc.Bytes())
+
+	fmt.Fprintf(w, "\n// UnmarshalCSVForm implements form.RowUnmarshaler.\n")
+	fmt.Fprintf(w, "func (v *%s) UnmarshalCSVForm(get func(key string) (string, error)) error {\n", name)
+	fmt.Fprintf(w, "%sreturn nil\n}\n", dec.Bytes())
+	return nil
+}
+
+// formFields writes the statements encoding and decoding the fields of
+// strct, reached through ex when encoding and dx when decoding, whose keys
+// start with start. filled names the flag recording whether an optional
+// sub-object has any value.
+func (g *generator) formFields(enc, dec *bytes.Buffer, strct, ex, dx, start, filled string, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("%s: structs nested too deeply", strct)
+	}
+	if start != "" {
+		start += " "
+	}
+	for _, f := range g.fields(strct) {
+		formtag, ok := f.tag.Lookup("csvform")
+		if !ok {
+			continue
+		}
+		if formtag == "" {
+			formtag = f.name
+		}
+		if formtag == "-" {
+			formtag = ""
+		}
+		key := start + formtag
+
+		switch f.typ.kind {
+		case kindSkip, kindTime, kindNull:
+			// no csvform fields to reach
+			continue
+		}
+		if f.err != nil {
+			return fmt.Errorf("%s.%s: %v", strct, f.name, f.err)
+		}
+		if !ast.IsExported(f.name) {
+			return fmt.Errorf("%s.%s: unexported fields cannot have a csvform tag", strct, f.name)
+		}
+
+		x, y := ex+"."+f.name, dx+"."+f.name
+		switch f.typ.kind {
+		case kindStruct:
+			if err := g.formFields(enc, dec, f.typ.strct, x, y, key, filled, depth+1); err != nil {
+				return err
+			}
+		case kindPtr:
+			// nil sub-objects are skipped, and only allocated when read
+			// if one of their fields has a value
+			fmt.Fprintf(enc, "if %s != nil {\n", x)
+			p, flag := g.tmp("p"), g.tmp("filled")
+			fmt.Fprintf(dec, "{\n%s := %s\nif %s == nil {\n%s = new(%s)\n}\n%s := false\n", p, y, p, p, f.typ.strct, flag)
+			if err := g.formFields(enc, dec, f.typ.strct, x, p, key, flag, depth+1); err != nil {
+				return err
+			}
+			fmt.Fprintf(enc, "}\n")
+			fmt.Fprintf(dec, "if %s || %s != nil {\n%s = %s\n}\n", flag, y, y, p)
+			if filled != "" {
+				fmt.Fprintf(dec, "if %s {\n%s = true\n}\n", flag, filled)
+			}
+			fmt.Fprintf(dec, "}\n")
+		default:
+			fmt.Fprintf(enc, "if err := set(%q, %s); err != nil {\nreturn err\n}\n", key, g.formFormat(f.typ, x))
+			fmt.Fprintf(dec, "if s, err := get(%q); err != nil {\nreturn err\n} else if s != \"\" {\n", key)
+			if filled != "" {
+				fmt.Fprintf(dec, "%s = true\n", filled)
+			}
+			bounds := tags.Options{}
+			for key, tag := range map[string]string{"min": "csvmin", "max": "csvmax"} {
+				if b := f.tag.Get(tag); b != "" {
+					bounds[key] = b
+				}
+			}
+			if err := g.formParse(dec, f.typ, y, f.name, key, bounds); err != nil {
+				return fmt.Errorf("%s.%s: %v", strct, f.name, err)
+			}
+			fmt.Fprintf(dec, "}\n")
+		}
+	}
+	return nil
+}
+
+// formFormat returns an expression formatting x like fmt's %v verb, which
+// the form package uses.
+func (g *generator) formFormat(ft fieldType, x string) string {
+	if ft.stringer {
+		g.use("fmt")
+		return "fmt.Sprint(" + x + ")"
+	}
+	switch ft.kind {
+	case kindString:
+		return unconvert(ft, "string", x)
+	case kindInt:
+		g.use("strconv")
+		return "strconv.FormatInt(" + unconvert(ft, "int64", x) + ", 10)"
+	case kindUint:
+		g.use("strconv")
+		return "strconv.FormatUint(" + unconvert(ft, "uint64", x) + ", 10)"
+	case kindFloat:
+		g.use("strconv")
+		return fmt.Sprintf("strconv.FormatFloat(%s, 'g', -1, %d)", unconvert(ft, "float64", x), ft.bits)
+	case kindBool:
+		g.use("strconv")
+		return "strconv.FormatBool(" + unconvert(ft, "bool", x) + ")"
+	}
+	g.use("fmt")
+	return "fmt.Sprint(" + x + ")"
+}
+
+// formParse writes statements parsing s into x, the field reached by key,
+// and checking it against bounds, its csvmin and csvmax tags.
+func (g *generator) formParse(w *bytes.Buffer, ft fieldType, x, name, key string, bounds tags.Options) error {
+	fail := func(msg string) string {
+		g.use("errors")
+		return fmt.Sprintf("return errors.New(%q)\n", "csv: "+name+" +  "+msg)
+	}
+	rangeErr := func() string {
+		g.use("reflect")
+		g.use(csvImport)
+		return fmt.Sprintf("if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {\n"+
+			"return &csv.RangeError{Column: %q, Value: s, Type: reflect.TypeOf(%s)}\n}\n", key, x)
+	}
+	switch ft.kind {
+	case kindString:
+		fmt.Fprintf(w, "%s = %s\n", x, convert(ft, "string", "s"))
+	case kindInt:
+		g.use("strconv")
+		fmt.Fprintf(w, "n, err := strconv.ParseInt(s, 10, %s)\nif err != nil {\n%s%s}\n%s = %s\n",
+			bitsArg(ft), rangeErr(), fail("Must be a a number"), x, convert(ft, "int64", "n"))
+		return g.bounds(w, ft, x, key, "", bounds)
+	case kindUint:
+		g.use("strconv")
+		fmt.Fprintf(w, "n, err := strconv.ParseUint(s, 10, %s)\nif err != nil {\n%s%s}\n%s = %s\n",
+			bitsArg(ft), rangeErr(), fail("Must be a a number"), x, convert(ft, "uint64", "n"))
+		return g.bounds(w, ft, x, key, "", bounds)
+	case kindFloat:
+		g.use("strconv")
+		fmt.Fprintf(w, "f, err := strconv.ParseFloat(s, %d)\nif err != nil {\n%s%s}\n%s = %s\n",
+			ft.bits, rangeErr(), fail("Must be a a number"), x, convert(ft, "float64", "f"))
+		return g.bounds(w, ft, x, key, "", bounds)
+	case kindBool:
+		g.use("strconv")
+		fmt.Fprintf(w, "b, err := strconv.ParseBool(s)\nif err != nil {\n%s}\n%s = %s\n",
+			fail("Must be either true or false"), x, convert(ft, "bool", "b"))
+	}
+	return nil
+}
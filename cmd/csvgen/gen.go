@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds struct nesting, which also catches recursive types.
+const maxDepth = 16
+
+const csvImport = "github.com/xiphoid24/csv"
+
+type kind int
+
+const (
+	kindSkip kind = iota // no column, like slices, maps and funcs
+	kindString
+	kindInt
+	kindUint
+	kindFloat
+	kindBool
+	kindTime
+	kindNull
+	kindStruct
+	kindPtr
+)
+
+// fieldType describes how a field is read and written.
+type fieldType struct {
+	kind kind
+	// name is the Go type parsed values are converted to.
+	name string
+	// bits is the size of numbers, 0 for int and uint.
+	bits int
+	// stringer is set for named types with a String method, which fmt
+	// uses for %v.
+	stringer bool
+	// null is the value field of a database/sql Null type and inner its
+	// type.
+	null  string
+	inner *fieldType
+	// strct names the struct type of kindStruct and kindPtr fields.
+	strct string
+}
+
+var builtins = map[string]fieldType{
+	"string":  {kind: kindString, name: "string"},
+	"bool":    {kind: kindBool, name: "bool"},
+	"int":     {kind: kindInt, name: "int"},
+	"int8":    {kind: kindInt, name: "int8", bits: 8},
+	"int16":   {kind: kindInt, name: "int16", bits: 16},
+	"int32":   {kind: kindInt, name: "int32", bits: 32},
+	"rune":    {kind: kindInt, name: "rune", bits: 32},
+	"int64":   {kind: kindInt, name: "int64", bits: 64},
+	"uint":    {kind: kindUint, name: "uint"},
+	"uint8":   {kind: kindUint, name: "uint8", bits: 8},
+	"byte":    {kind: kindUint, name: "byte", bits: 8},
+	"uint16":  {kind: kindUint, name: "uint16", bits: 16},
+	"uint32":  {kind: kindUint, name: "uint32", bits: 32},
+	"uint64":  {kind: kindUint, name: "uint64", bits: 64},
+	"float32": {kind: kindFloat, name: "float32", bits: 32},
+	"float64": {kind: kindFloat, name: "float64", bits: 64},
+}
+
+var timeField = fieldType{kind: kindTime, name: "time.Time"}
+
+// nullTypes maps the database/sql Null types to their value fields.
+var nullTypes = map[string]struct {
+	field string
+	inner fieldType
+}{
+	"NullString":  {"String", builtins["string"]},
+	"NullInt64":   {"Int64", builtins["int64"]},
+	"NullInt32":   {"Int32", builtins["int32"]},
+	"NullInt16":   {"Int16", builtins["int16"]},
+	"NullByte":    {"Byte", builtins["byte"]},
+	"NullFloat64": {"Float64", builtins["float64"]},
+	"NullBool":    {"Bool", builtins["bool"]},
+	"NullTime":    {"Time", timeField},
+}
+
+type typeDecl struct {
+	expr  ast.Expr
+	file  *ast.File
+	alias bool
+}
+
+type field struct {
+	name string
+	typ  fieldType
+	err  error
+	tag  reflect.StructTag
+}
+
+type generator struct {
+	pkg   string
+	decls map[string]typeDecl
+	// methods lists the methods of each named type; valueMethods only
+	// those with a value receiver.
+	methods      map[string]map[string]bool
+	valueMethods map[string]map[string]bool
+
+	imports map[string]bool
+	vars    int
+}
+
+// newGenerator parses the non-test Go files in dir.
+func newGenerator(dir string) (*generator, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{
+		decls:        map[string]typeDecl{},
+		methods:      map[string]map[string]bool{},
+		valueMethods: map[string]map[string]bool{},
+	}
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if g.pkg == "" {
+			g.pkg = f.Name.Name
+		}
+		if f.Name.Name != g.pkg {
+			continue
+		}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				if d.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range d.Specs {
+					ts := spec.(*ast.TypeSpec)
+					g.decls[ts.Name.Name] = typeDecl{ts.Type, f, ts.Assign.IsValid()}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil || len(d.Recv.List) != 1 {
+					continue
+				}
+				recv, value := d.Recv.List[0].Type, true
+				if star, ok := recv.(*ast.StarExpr); ok {
+					recv, value = star.X, false
+				}
+				id, ok := recv.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				addMethod(g.methods, id.Name, d.Name.Name)
+				if value {
+					addMethod(g.valueMethods, id.Name, d.Name.Name)
+				}
+			}
+		}
+	}
+	if g.pkg == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return g, nil
+}
+
+func addMethod(m map[string]map[string]bool, typ, method string) {
+	if m[typ] == nil {
+		m[typ] = map[string]bool{}
+	}
+	m[typ][method] = true
+}
+
+// generate returns the formatted source of the methods for types.
+func (g *generator) generate(types []string, args string) ([]byte, error) {
+	g.imports = map[string]bool{}
+	var body bytes.Buffer
+	for _, name := range types {
+		d, ok := g.decls[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found", name)
+		}
+		if _, ok := d.expr.(*ast.StructType); !ok || d.alias {
+			return nil, fmt.Errorf("%s is not a struct type", name)
+		}
+
+		hasCSV, hasForm := g.hasTag(name, "csv", 0), g.hasTag(name, "csvform", 0)
+		if hasCSV || !hasForm {
+			if err := g.csvMethods(&body, name); err != nil {
+				return nil, err
+			}
+		}
+		if hasForm {
+			if err := g.formMethods(&body, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by \"csvgen %s\"; DO NOT EDIT.\n\n", args)
+	fmt.Fprintf(&src, "package %s\n\n", g.pkg)
+	var std []string
+	for path := range g.imports {
+		if path != csvImport {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	src.WriteString("import (\n")
+	for _, path := range std {
+		fmt.Fprintf(&src, "\t%q\n", path)
+	}
+	if g.imports[csvImport] {
+		fmt.Fprintf(&src, "\n\t%q\n", csvImport)
+	}
+	src.WriteString(")\n")
+	src.Write(body.Bytes())
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting output: %v\n%s", err, src.Bytes())
+	}
+	return out, nil
+}
+
+func (g *generator) use(path string) {
+	g.imports[path] = true
+}
+
+// tmp returns a variable name unique within the generated file.
+func (g *generator) tmp(prefix string) string {
+	g.vars++
+	return prefix + strconv.Itoa(g.vars)
+}
+
+// hasTag reports whether a struct type or any struct nested in it has a
+// field with the given tag key.
+func (g *generator) hasTag(strct, key string, depth int) bool {
+	if depth > maxDepth {
+		return false
+	}
+	for _, f := range g.fields(strct) {
+		if _, ok := f.tag.Lookup(key); ok {
+			return true
+		}
+		if (f.typ.kind == kindStruct || f.typ.kind == kindPtr) && f.typ.strct != "" && g.hasTag(f.typ.strct, key, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// fields lists the fields of a struct type declared in the package.
+func (g *generator) fields(strct string) []field {
+	d := g.decls[strct]
+	st, ok := d.expr.(*ast.StructType)
+	if !ok {
+		return nil
+	}
+	var fields []field
+	for _, f := range st.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			s, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(s)
+		}
+		typ, err := g.resolve(f.Type, d.file, 0)
+		if len(f.Names) == 0 {
+			fields = append(fields, field{embeddedName(f.Type), typ, err, tag})
+			continue
+		}
+		for _, name := range f.Names {
+			fields = append(fields, field{name.Name, typ, err, tag})
+		}
+	}
+	return fields
+}
+
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// resolve works out the fieldType of a type expression found in file.
+func (g *generator) resolve(expr ast.Expr, file *ast.File, depth int) (fieldType, error) {
+	if depth > maxDepth {
+		return fieldType{}, fmt.Errorf("type definitions nested too deeply")
+	}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if d, ok := g.decls[t.Name]; ok {
+			return g.named(t.Name, d, depth)
+		}
+		if ft, ok := builtins[t.Name]; ok {
+			return ft, nil
+		}
+		return fieldType{}, fmt.Errorf("unsupported type %s", t.Name)
+	case *ast.SelectorExpr:
+		pkg, ok := t.X.(*ast.Ident)
+		if !ok {
+			break
+		}
+		switch importPath(file, pkg.Name) {
+		case "time":
+			if t.Sel.Name == "Time" {
+				return timeField, nil
+			}
+		case "database/sql":
+			if nt, ok := nullTypes[t.Sel.Name]; ok {
+				inner := nt.inner
+				return fieldType{kind: kindNull, name: "sql." + t.Sel.Name, null: nt.field, inner: &inner}, nil
+			}
+		}
+		return fieldType{}, fmt.Errorf("unsupported type %s.%s", pkg.Name, t.Sel.Name)
+	case *ast.StarExpr:
+		ft, err := g.resolve(t.X, file, depth+1)
+		if err != nil {
+			return fieldType{kind: kindPtr}, err
+		}
+		if ft.kind != kindStruct {
+			return fieldType{kind: kindSkip}, nil
+		}
+		ft.kind = kindPtr
+		return ft, nil
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return fieldType{kind: kindSkip}, nil
+	}
+	return fieldType{}, fmt.Errorf("unsupported type %T", expr)
+}
+
+// named resolves a type declared in the package.
+func (g *generator) named(name string, d typeDecl, depth int) (fieldType, error) {
+	if _, ok := d.expr.(*ast.StructType); ok {
+		if g.methods[name]["Scan"] && g.methods[name]["Value"] {
+			return fieldType{}, fmt.Errorf("%s implements sql.Scanner, which is not supported", name)
+		}
+		return fieldType{kind: kindStruct, name: name, strct: name}, nil
+	}
+	ft, err := g.resolve(d.expr, d.file, depth+1)
+	if err != nil || d.alias {
+		return ft, err
+	}
+	switch ft.kind {
+	case kindString, kindInt, kindUint, kindFloat, kindBool:
+		ft.name = name
+		ft.stringer = g.valueMethods[name]["String"]
+		return ft, nil
+	case kindSkip:
+		return ft, nil
+	}
+	return fieldType{}, fmt.Errorf("unsupported type %s", name)
+}
+
+func importPath(file *ast.File, name string) string {
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		local := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			local = imp.Name.Name
+		}
+		if local == name {
+			return path
+		}
+	}
+	return ""
+}
+
+func bitsArg(ft fieldType) string {
+	if ft.bits == 0 {
+		return "strconv.IntSize"
+	}
+	return strconv.Itoa(ft.bits)
+}
+
+// convert wraps expr, of type builtin, in a conversion to ft's type unless
+// it already has it.
+func convert(ft fieldType, builtin, expr string) string {
+	if ft.name == builtin {
+		return expr
+	}
+	return ft.name + "(" + expr + ")"
+}
+
+// unconvert wraps x, of ft's type, in a conversion to builtin unless it
+// already has it.
+func unconvert(ft fieldType, builtin, x string) string {
+	if ft.name == builtin {
+		return x
+	}
+	return builtin + "(" + x + ")"
+}
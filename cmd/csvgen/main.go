@@ -0,0 +1,77 @@
+// Command csvgen writes reflection-free encoding methods for struct types.
+// Types with csv tags get MarshalCSVRow and UnmarshalCSVRow, which Marshal
+// and Unmarshal in package csv use instead of reflection. Types with
+// csvform tags get MarshalCSVForm and UnmarshalCSVForm for package
+// csv/form.
+//
+// Usage:
+//
+//	//go:generate csvgen -type Order,Customer
+//
+// csvgen reads the package in the current directory, or the directory
+// given as argument, and writes the methods to <type>_csv.go, named after
+// the first type, unless -o is given.
+//
+// Columns are named as at run time, including dotted prefixes for nested
+// structs and spaced prefixes for csvform keys. Supported fields are
+// strings, integers, floats, bools, time.Time, the database/sql Null types
+// and nested structs declared in the same package. The tag options base,
+// format, prec, true, false, layout, min and max, and the csvmin and csvmax
+// tags of csvform fields, are compiled into the methods, so they must be
+// regenerated whenever a tag changes. The locale, currency and percent
+// options are not supported.
+//
+// Generated methods know nothing of encoder and decoder options, so package
+// csv only calls them while those options are left at their defaults, and
+// falls back to reflection otherwise.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of type `names`")
+	output := flag.String("o", "", "output `file`, default <type>_csv.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: csvgen -type T[,T...] [-o file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	g, err := newGenerator(dir)
+	if err != nil {
+		fatal(err)
+	}
+	src, err := g.generate(types, strings.Join(os.Args[1:], " "))
+	if err != nil {
+		fatal(err)
+	}
+
+	path := *output
+	if path == "" {
+		path = filepath.Join(dir, strings.ToLower(types[0])+"_csv.go")
+	}
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "csvgen: %v\n", err)
+	os.Exit(1)
+}
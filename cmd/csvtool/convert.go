@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"unicode"
+)
+
+func convertCmd(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	from := fs.String("from", ",", "input delimiter")
+	to := fs.String("to", ",", "output delimiter")
+	normalize := fs.Bool("normalize-headers", false, "lower-case headers and replace spaces and punctuation with _")
+	out := fs.String("o", "-", "output `file`, - for standard output")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		usage()
+		return exitError
+	}
+
+	fromComma, err := delimiter(*from)
+	if err != nil {
+		return fail(err)
+	}
+	toComma, err := delimiter(*to)
+	if err != nil {
+		return fail(err)
+	}
+	b, err := readInput(fs.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+
+	var fix func([]string) []string
+	if *normalize {
+		fix = normalizeHeaders
+	}
+	var buf bytes.Buffer
+	if err := copyCSV(bytes.NewReader(b), &buf, fromComma, toComma, fix); err != nil {
+		return fail(err)
+	}
+
+	if *out == "-" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*out, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// normalizeHeaders turns headers like " Customer E-Mail " into
+// "customer_e_mail" and strips a leading byte order mark.
+func normalizeHeaders(header []string) []string {
+	out := make([]string, len(header))
+	for i, h := range header {
+		h = strings.TrimPrefix(h, "\ufeff")
+		h = strings.ToLower(strings.TrimSpace(h))
+		h = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return '_'
+		}, h)
+		for strings.Contains(h, "__") {
+			h = strings.Replace(h, "__", "_", -1)
+		}
+		out[i] = strings.Trim(h, "_")
+	}
+	return out
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xiphoid24/csv"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want schema
+		err  bool
+	}{
+		{
+			"columns:\n  - name: id\n    type: int\n    required: true\n  - name: \"when\" # comment\n    type: time\n    format: 'Jan 2, 2006'\n",
+			schema{Columns: []column{
+				{Name: "id", Type: "int", Required: true},
+				{Name: "when", Type: "time", Format: "Jan 2, 2006"},
+			}},
+			false,
+		},
+		{
+			"---\nnulls: [NULL, \"N/A\"]\ncolumns:\n  -\n    name: a\n",
+			schema{Columns: []column{{Name: "a"}}, Nulls: []string{"NULL", "N/A"}},
+			false,
+		},
+		{
+			"nulls:\n  - NULL\n  - '-'\ncolumns:\n- name: a\n",
+			schema{Columns: []column{{Name: "a"}}, Nulls: []string{"NULL", "-"}},
+			false,
+		},
+		{"rows:\n  - name: a\n", schema{}, true},
+		{"columns:\n  name: a\n", schema{}, true},
+		{"columns:\n  - name\n", schema{}, true},
+		{"  - name: a\n", schema{}, true},
+	}
+	for _, tt := range tests {
+		var got schema
+		err := parseYAML([]byte(tt.in), &got)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: err = %v", tt.in, err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	schemaPath := write("schema.yaml", "nulls: [NULL]\ncolumns:\n  - name: id\n    type: int\n    required: true\n  - name: qty\n    type: int\n    max: 10\n")
+
+	tests := []struct {
+		name string
+		csv  string
+		args []string
+		want int
+	}{
+		{"ok", "id,qty\n1,2\n2,NULL\n", nil, exitOK},
+		{"semicolons", "id;qty\n1;2\n", []string{"-delim", ";"}, exitOK},
+		{"required", "id,qty\n1,2\n,3\n", nil, exitInvalid},
+		{"required null", "id,qty\nNULL,3\n", nil, exitInvalid},
+		{"bad value", "id,qty\nx,2\n", nil, exitInvalid},
+		{"over max", "id,qty\n1,11\n", nil, exitInvalid},
+		{"missing column", "id\n1\n", nil, exitInvalid},
+		{"ragged", "id,qty\n1,2\n3\n", nil, exitInvalid},
+		{"ragged semicolons", "id;qty\n1;2;3\n", []string{"-delim", ";"}, exitInvalid},
+		{"bare quote", "id,qty\n1,2\"x\n", nil, exitInvalid},
+	}
+	for _, tt := range tests {
+		path := write("in.csv", tt.csv)
+		args := append([]string{"-schema", schemaPath}, tt.args...)
+		if got := validateCmd(append(args, path)); got != tt.want {
+			t.Errorf("%s: exit %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	usageErrors := [][]string{
+		{},
+		{"-schema", schemaPath},
+		{"-schema", filepath.Join(dir, "nosuch.json"), write("in.csv", "id\n1\n")},
+		{"-schema", schemaPath, filepath.Join(dir, "nosuch.csv")},
+		{"-schema", schemaPath, "-delim", "ab", write("in.csv", "id\n1\n")},
+	}
+	for _, args := range usageErrors {
+		if got := validateCmd(args); got != exitError {
+			t.Errorf("%q: exit %d, want %d", args, got, exitError)
+		}
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	s := &schema{Columns: []column{{Name: "id", Type: "int", Required: true}}, Nulls: []string{"-"}}
+	dec, err := csv.NewCSVDecoder([]byte("id\n1\n-\nx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`row 2, column "id": value is required`,
+		`row 3, column "id": "x" is not a valid int`,
+	}
+	if got := validate(dec, s, 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := raggedRow([]byte("a,b\n1,2\n\"x\ny\",2,3\n"), ',', 3); got != "row 2: 3 fields, the header has 2" {
+		t.Errorf("raggedRow = %q", got)
+	}
+}
+
+func TestNormalizeHeaders(t *testing.T) {
+	in := []string{"\ufeff Customer E-Mail ", "Order #", "ID", "Städte__Zahl", "--"}
+	want := []string{"customer_e_mail", "order", "id", "städte_zahl", ""}
+	if got := normalizeHeaders(in); !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeHeaders = %q, want %q", got, want)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.csv")
+	if err := os.WriteFile(in, []byte("First Name;Note\nann;\"a;b\"\nbob;x,y\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-from", ";"}, "First Name,Note\nann,a;b\nbob,\"x,y\"\n"},
+		{[]string{"-from", ";", "-to", "\\t", "-normalize-headers"}, "first_name\tnote\nann\ta;b\nbob\tx,y\n"},
+	}
+	for _, tt := range tests {
+		out := filepath.Join(dir, "out.csv")
+		args := append(append(tt.args, "-o", out), in)
+		if got := convertCmd(args); got != exitOK {
+			t.Errorf("%q: exit %d, want %d", tt.args, got, exitOK)
+			continue
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.args, b, tt.want)
+		}
+	}
+
+	for _, args := range [][]string{{}, {"-from", "ab", in}, {filepath.Join(dir, "nosuch.csv")}} {
+		if got := convertCmd(args); got != exitError {
+			t.Errorf("%q: exit %d, want %d", args, got, exitError)
+		}
+	}
+}
+
+// stdout runs fn with its standard output captured.
+func stdout(t *testing.T, fn func() int) (string, int) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	code := fn()
+	os.Stdout = old
+	w.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), code
+}
+
+func TestInfer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.csv")
+	if err := os.WriteFile(path, []byte("id,price,when\n1,2.5,2024-01-02\n2,,2024-02-03\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, code := stdout(t, func() int { return inferCmd([]string{"-name", "Item", path}) })
+	if code != exitOK || !strings.Contains(got, "type Item struct") || !strings.Contains(got, `csv:"price"`) {
+		t.Errorf("infer: exit %d, output\n%s", code, got)
+	}
+
+	got, code = stdout(t, func() int { return inferCmd([]string{"-schema", path}) })
+	if code != exitOK {
+		t.Fatalf("infer -schema: exit %d", code)
+	}
+	var s schema
+	if err := json.Unmarshal([]byte(got), &s); err != nil {
+		t.Fatalf("infer -schema: %v\n%s", err, got)
+	}
+	want := []column{
+		{Name: "id", Type: "int", Required: true},
+		{Name: "price", Type: "float"},
+		{Name: "when", Type: "time", Required: true, Format: "2006-01-02"},
+	}
+	if !reflect.DeepEqual(s.Columns, want) {
+		t.Errorf("infer -schema = %+v, want %+v", s.Columns, want)
+	}
+
+	// the inferred schema validates the file it came from
+	schemaPath := filepath.Join(filepath.Dir(path), "schema.json")
+	if err := os.WriteFile(schemaPath, []byte(got), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := validateCmd([]string{"-schema", schemaPath, path}); code != exitOK {
+		t.Errorf("validate with the inferred schema: exit %d", code)
+	}
+
+	if code := inferCmd(nil); code != exitError {
+		t.Errorf("infer without a file: exit %d, want %d", code, exitError)
+	}
+}
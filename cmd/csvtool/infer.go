@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/xiphoid24/csv"
+)
+
+func inferCmd(args []string) int {
+	fs := flag.NewFlagSet("infer", flag.ContinueOnError)
+	rows := fs.Int("rows", 100, "sample the first `n` rows, 0 for all")
+	name := fs.String("name", "Row", "struct type `name`")
+	asSchema := fs.Bool("schema", false, "print a schema for validate instead of a Go struct")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		usage()
+		return exitError
+	}
+
+	b, err := readInput(fs.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+	s, err := csv.InferSchema(bytes.NewReader(b), *rows)
+	if err != nil {
+		return fail(err)
+	}
+
+	if s.Delimiter != "" {
+		fmt.Fprintf(os.Stderr, "csvtool: %s: delimiter is %q\n", fs.Arg(0), s.Delimiter)
+	}
+	if s.NoHeader {
+		fmt.Fprintf(os.Stderr, "csvtool: %s: no header row\n", fs.Arg(0))
+	}
+
+	if !*asSchema {
+		fmt.Print(s.GoStruct(*name))
+		return exitOK
+	}
+
+	out := schema{Columns: make([]column, len(s.Columns))}
+	for i, col := range s.Columns {
+		out.Columns[i] = column{
+			Name:     col.Name,
+			Type:     string(col.Type),
+			Required: !col.Nullable,
+			Format:   col.Format,
+		}
+	}
+	j, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Printf("%s\n", j)
+	return exitOK
+}
@@ -0,0 +1,92 @@
+// Command csvtool validates CSV files against a column schema, converts
+// them between delimiters and infers schemas from sample files.
+//
+// Usage:
+//
+//	csvtool validate -schema schema.json [-delim ;] file.csv
+//	csvtool convert [-from ;] [-to ,] [-normalize-headers] [-o out.csv] file.csv
+//	csvtool infer [-rows 100] [-name Row] [-schema] file.csv
+//
+// infer prints a Go struct with csv tags for the file, or with -schema a
+// JSON schema that validate accepts.
+//
+// A schema is a JSON or YAML document listing the expected columns:
+//
+//	columns:
+//	  - name: id
+//	    type: int
+//	    required: true
+//	  - name: created
+//	    type: time
+//	    format: 2006-01-02
+//
+// Column types are string, int, uint, float, bool and time. The format is a
+// time layout for time columns, a number locale such as "de" for numeric
+// columns and "Y/N" style true/false strings for bool columns. Numeric
+// columns also accept min and max. A top-level nulls list, such as
+// [NULL, N/A], names cell values that count as missing.
+//
+// csvtool exits with status 0 on success, 1 when the file fails validation,
+// malformed CSV included, and 2 on usage or I/O errors.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitError   = 2
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitError)
+	}
+
+	var code int
+	switch os.Args[1] {
+	case "validate":
+		code = validateCmd(os.Args[2:])
+	case "convert":
+		code = convertCmd(os.Args[2:])
+	case "infer":
+		code = inferCmd(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "csvtool: unknown command %q\n", os.Args[1])
+		usage()
+		code = exitError
+	}
+	os.Exit(code)
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage:
+  csvtool validate -schema schema.json [-delim ;] file.csv
+  csvtool convert [-from ;] [-to ,] [-normalize-headers] [-o out.csv] file.csv
+  csvtool infer [-rows 100] [-name Row] [-schema] file.csv
+`)
+}
+
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "csvtool: %v\n", err)
+	return exitError
+}
+
+// delimiter parses a delimiter flag, accepting `\t` and "tab" for tabs.
+func delimiter(s string) (rune, error) {
+	switch s {
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	r := []rune(s)
+	if len(r) != 1 {
+		return 0, fmt.Errorf("invalid delimiter %q", s)
+	}
+	return r[0], nil
+}
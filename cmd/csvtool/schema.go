@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xiphoid24/csv"
+)
+
+type schema struct {
+	Columns []column `json:"columns"`
+	// Nulls are cell values, such as "NULL" or "N/A", that count as
+	// missing, like an empty cell.
+	Nulls []string `json:"nulls,omitempty"`
+}
+
+type column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	Format   string `json:"format,omitempty"`
+	Min      string `json:"min,omitempty"`
+	Max      string `json:"max,omitempty"`
+}
+
+// csvColumn returns the csv package column that converts the cells of c.
+// Required columns are checked by validate, which also treats null tokens
+// as empty, so the column is always nullable.
+func (c column) csvColumn() csv.Column {
+	return csv.Column{
+		Name:     c.Name,
+		Type:     csv.ColumnType(c.Type),
+		Nullable: true,
+		Format:   c.Format,
+		Min:      c.Min,
+		Max:      c.Max,
+	}
+}
+
+// loadSchema reads a schema file. Files ending in .yaml or .yml are read
+// as YAML, everything else as JSON.
+func loadSchema(path string) (*schema, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(schema)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = parseYAML(b, s)
+	default:
+		err = json.Unmarshal(b, s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	if len(s.Columns) == 0 {
+		return nil, fmt.Errorf("%s: no columns", path)
+	}
+	for i, col := range s.Columns {
+		if col.Name == "" {
+			return nil, fmt.Errorf("%s: column %d has no name", path, i+1)
+		}
+		switch col.Type {
+		case "":
+			s.Columns[i].Type = "string"
+		case "string", "int", "uint", "float", "bool", "time":
+		default:
+			return nil, fmt.Errorf("%s: column %q has unknown type %q", path, col.Name, col.Type)
+		}
+	}
+	return s, nil
+}
+
+// parseYAML understands the small subset of YAML a schema needs: a
+// top-level columns key holding a list of flat key/value maps, and a
+// top-level nulls key holding a list of strings, either as a block or
+// inline as [a, b].
+func parseYAML(b []byte, s *schema) error {
+	var cur map[string]string
+	var items []map[string]string
+	section := ""
+
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		if line[0] != ' ' && line[0] != '-' {
+			i := strings.Index(trimmed, ":")
+			if i < 0 {
+				return fmt.Errorf("line %d: unexpected %q", n, trimmed)
+			}
+			section = trimmed[:i]
+			rest := strings.TrimSpace(trimmed[i+1:])
+			switch {
+			case section == "columns" && rest == "":
+			case section == "nulls" && rest == "":
+			case section == "nulls" && strings.HasPrefix(rest, "[") && strings.HasSuffix(rest, "]"):
+				for _, v := range strings.Split(rest[1:len(rest)-1], ",") {
+					if v = strings.TrimSpace(v); v != "" {
+						s.Nulls = append(s.Nulls, unquote(v))
+					}
+				}
+				section = ""
+			default:
+				return fmt.Errorf("line %d: unexpected %q", n, trimmed)
+			}
+			continue
+		}
+
+		switch section {
+		case "nulls":
+			if !strings.HasPrefix(trimmed, "- ") {
+				return fmt.Errorf("line %d: expected a list item", n)
+			}
+			s.Nulls = append(s.Nulls, unquote(strings.TrimSpace(trimmed[2:])))
+			continue
+		case "columns":
+		default:
+			return fmt.Errorf("line %d: unexpected %q", n, trimmed)
+		}
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			cur = map[string]string{}
+			items = append(items, cur)
+			trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			if trimmed == "" {
+				continue
+			}
+		}
+		if cur == nil {
+			return fmt.Errorf("line %d: expected a list item", n)
+		}
+
+		i := strings.Index(trimmed, ":")
+		if i < 0 {
+			return fmt.Errorf("line %d: expected key: value", n)
+		}
+		cur[strings.TrimSpace(trimmed[:i])] = unquote(strings.TrimSpace(trimmed[i+1:]))
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		required, _ := strconv.ParseBool(item["required"])
+		s.Columns = append(s.Columns, column{
+			Name:     item["name"],
+			Type:     item["type"],
+			Required: required,
+			Format:   item["format"],
+			Min:      item["min"],
+			Max:      item["max"],
+		})
+	}
+	return nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	stdcsv "encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/xiphoid24/csv"
+)
+
+func validateCmd(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	schemaPath := fs.String("schema", "", "JSON or YAML schema `file`")
+	delim := fs.String("delim", ",", "input delimiter")
+	maxErrors := fs.Int("max-errors", 100, "stop after `n` errors, 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *schemaPath == "" || fs.NArg() != 1 {
+		usage()
+		return exitError
+	}
+
+	s, err := loadSchema(*schemaPath)
+	if err != nil {
+		return fail(err)
+	}
+	comma, err := delimiter(*delim)
+	if err != nil {
+		return fail(err)
+	}
+	b, err := readInput(fs.Arg(0))
+	if err != nil {
+		return fail(err)
+	}
+	if comma != ',' {
+		if b, err = recode(b, comma, ','); err != nil {
+			return invalid(fs.Arg(0), b, comma, err)
+		}
+	}
+
+	dec, err := csv.NewCSVDecoder(b)
+	if err != nil {
+		return invalid(fs.Arg(0), b, ',', err)
+	}
+
+	problems := validate(dec, s, *maxErrors)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "csvtool: %s: %d problem(s)\n", fs.Arg(0), len(problems))
+		return exitInvalid
+	}
+	return exitOK
+}
+
+// invalid reports an error reading b. Malformed CSV, such as a row with the
+// wrong number of fields, fails validation; anything else is an I/O error.
+func invalid(path string, b []byte, comma rune, err error) int {
+	var pe *stdcsv.ParseError
+	if !errors.As(err, &pe) {
+		return fail(err)
+	}
+	if pe.Err == stdcsv.ErrFieldCount {
+		fmt.Println(raggedRow(b, comma, pe.StartLine))
+	} else {
+		fmt.Printf("line %d: %v\n", pe.Line, pe.Err)
+	}
+	fmt.Fprintf(os.Stderr, "csvtool: %s: malformed CSV\n", path)
+	return exitInvalid
+}
+
+// raggedRow describes the record starting on line, which has a different
+// number of fields than the header. Rows are numbered like validate does.
+func raggedRow(b []byte, comma rune, line int) string {
+	cr := stdcsv.NewReader(bytes.NewReader(b))
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	width := 0
+	for row := 0; ; row++ {
+		rec, err := cr.Read()
+		if err != nil {
+			break
+		}
+		if row == 0 {
+			width = len(rec)
+		}
+		if l, _ := cr.FieldPos(0); l == line {
+			return fmt.Sprintf("row %d: %d fields, the header has %d", row, len(rec), width)
+		}
+	}
+	return fmt.Sprintf("line %d: wrong number of fields", line)
+}
+
+// validate checks every cell of every schema column and returns one message
+// per problem. Rows are numbered from 1, the first row after the header.
+func validate(dec *csv.CSVDecoder, s *schema, maxErrors int) []string {
+	var problems []string
+	report := func(format string, args ...interface{}) bool {
+		problems = append(problems, fmt.Sprintf(format, args...))
+		return maxErrors > 0 && len(problems) >= maxErrors
+	}
+
+	type check struct {
+		col      csv.Column
+		index    int
+		required bool
+	}
+	var checks []check
+	for _, col := range s.Columns {
+		i, ok := dec.HeaderMap[col.Name]
+		if !ok {
+			if report("header: missing column %q", col.Name) {
+				return problems
+			}
+			continue
+		}
+		// parsing an empty cell only checks the column's format
+		c := col.csvColumn()
+		if _, err := c.Parse(""); err != nil {
+			if report("schema: %s", strings.TrimPrefix(err.Error(), "csv: ")) {
+				return problems
+			}
+			continue
+		}
+		checks = append(checks, check{c, i, col.Required})
+	}
+
+	for row := 1; row < len(dec.Rows); row++ {
+		for _, chk := range checks {
+			cell := dec.GetFieldInRow(row, chk.index)
+			if cell == "" || s.isNull(cell) {
+				if chk.required && report("row %d, column %q: value is required", row, chk.col.Name) {
+					return problems
+				}
+				continue
+			}
+			if _, err := chk.col.Parse(cell); err != nil {
+				if report("row %d, column %q: %s", row, chk.col.Name, describe(err, cell, chk.col)) {
+					return problems
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// isNull reports whether cell is one of the schema's null tokens.
+func (s *schema) isNull(cell string) bool {
+	for _, tok := range s.Nulls {
+		if cell == tok {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(err error, cell string, col csv.Column) string {
+	if re, ok := err.(*csv.RangeError); ok {
+		switch {
+		case re.Min != "":
+			return fmt.Sprintf("%q is below the minimum of %s", cell, re.Min)
+		case re.Max != "":
+			return fmt.Sprintf("%q is above the maximum of %s", cell, re.Max)
+		}
+		return fmt.Sprintf("%q is out of range for %s", cell, col.Type)
+	}
+	if col.Type == "time" && col.Format != "" {
+		return fmt.Sprintf("%q is not a valid time in the layout %q", cell, col.Format)
+	}
+	return fmt.Sprintf("%q is not a valid %s", cell, col.Type)
+}
+
+// readInput reads a file, or standard input when path is "-".
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// recode rewrites b from one delimiter to another.
+func recode(b []byte, from, to rune) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := copyCSV(bytes.NewReader(b), &buf, from, to, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// copyCSV copies records from r to w, changing the delimiter and passing
+// the header through fix when it is not nil.
+func copyCSV(r io.Reader, w io.Writer, from, to rune, fix func([]string) []string) error {
+	cr := stdcsv.NewReader(r)
+	cr.Comma = from
+	cw := stdcsv.NewWriter(w)
+	cw.Comma = to
+
+	for n := 0; ; n++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 && fix != nil {
+			rec = fix(rec)
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,107 @@
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// errZstd is returned for zstd data, which needs a codec outside the
+// standard library.
+var errZstd = fmt.Errorf("csv: zstd compression is not supported")
+
+// decompress returns a reader of the data of r, decompressed when it
+// starts with the gzip magic bytes.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	if bytes.HasPrefix(magic, zstdMagic) {
+		return nil, errZstd
+	}
+	if !bytes.HasPrefix(magic, gzipMagic) {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
+
+// codec returns the compression named by the extension of path: "gzip",
+// or "" for none.
+func codec(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz", ".gzip":
+		return "gzip", nil
+	case ".zst", ".zstd":
+		return "", errZstd
+	}
+	return "", nil
+}
+
+// UnmarshalFile decodes the file at path into v, a pointer to a slice of
+// structs. Gzip files are decompressed whatever their name.
+func UnmarshalFile(path string, v interface{}, opts ...DecoderOption) error {
+	if _, err := codec(path); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return UnmarshalReader(f, v, opts...)
+}
+
+// MarshalFile writes v, a struct or a slice of structs, to the file at
+// path, gzip-compressed when path ends in .gz. The output is written to a
+// temporary file that replaces path once complete, so a failed write
+// leaves no partial file behind.
+func MarshalFile(path string, v interface{}, opts ...EncoderOption) error {
+	c, err := codec(path)
+	if err != nil {
+		return err
+	}
+	if c == "gzip" {
+		opts = append(opts[:len(opts):len(opts)], GzipOutput())
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(f, v, opts); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// writeFile encodes v to f and closes it.
+func writeFile(f *os.File, v interface{}, opts []EncoderOption) error {
+	w := bufio.NewWriter(f)
+	if err := MarshalTo(w, v, opts...); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,85 @@
+package csv
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGzipFiles(t *testing.T) {
+	type row struct {
+		Name string
+		Qty  int
+	}
+	in := []row{{"a", 1}, {"b, c", 2}}
+	dir := t.TempDir()
+	for _, name := range []string{"rows.csv", "rows.csv.gz"} {
+		path := dir + "/" + name
+		if err := MarshalFile(path, in); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gz := bytes.HasPrefix(b, gzipMagic); gz != strings.HasSuffix(name, ".gz") {
+			t.Errorf("%s: gzip = %v", name, gz)
+		}
+		var out []row
+		if err := UnmarshalFile(path, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%s: got %v, want %v", name, out, in)
+		}
+	}
+	if err := MarshalFile(dir+"/rows.csv.zst", in); err == nil {
+		t.Error("expected an error for zstd")
+	}
+
+	b, err := Marshal(in, GzipOutput())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCSVDecoder(b, WithLimits(Limits{MaxBytes: 10})); err == nil {
+		t.Error("expected MaxBytes to apply to the decompressed input")
+	}
+}
+
+func TestMarshalFileError(t *testing.T) {
+	type row struct {
+		Name string
+	}
+	dir := t.TempDir()
+	path := dir + "/rows.csv"
+	if err := MarshalFile(path, []row{{"kept"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarshalFile(path, []int{1}); err == nil {
+		t.Fatal("expected an error for a slice of ints")
+	}
+	if err := MarshalFile(dir+"/new.csv.gz", 1); err == nil {
+		t.Fatal("expected an error for an int")
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Name\nkept"; string(b) != want {
+		t.Errorf("after a failed MarshalFile the file holds %q, want %q", b, want)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("files left behind: %v", names)
+	}
+}
@@ -0,0 +1,243 @@
+package csv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/xiphoid24/csv/internal/shared"
+)
+
+// Config holds decoder and encoder options together with the plans worked
+// out for each struct type it has decoded or encoded. A Config never
+// changes once built, so one value can be shared by any number of
+// goroutines; every call creates its own CSVDecoder or CSVEncoder.
+type Config struct {
+	decOpts []DecoderOption
+	encOpts []EncoderOption
+
+	// plans is shared by every Config derived from the same NewConfig,
+	// so the package-level functions, which derive one per call, reuse
+	// the plans of earlier calls with the same options. A Config that was
+	// not made by NewConfig caches nothing.
+	plans *planCache
+}
+
+// maxPlans bounds the encoder plans a planCache keeps, since encoder
+// options such as SelectColumns can make for any number of them.
+const maxPlans = 1024
+
+type planCache struct {
+	// dec maps a struct type to its decode plan.
+	dec sync.Map
+	// enc maps an encKey to an encoder holding its encoded header.
+	enc sync.Map
+	n   int64
+}
+
+// encKey identifies an encoder plan by type and encoder settings. When the
+// settings cannot be compared, such as with a Labeler, cfg is set instead
+// and the plan is only reused by the same Config.
+type encKey struct {
+	t        reflect.Type
+	settings string
+	cfg      *Config
+}
+
+var defaultConfig = NewConfig()
+
+// NewConfig returns a Config with the default options.
+func NewConfig() *Config {
+	return &Config{plans: &planCache{}}
+}
+
+// WithDecoder returns a copy of cfg with opts added to its decoder options.
+func (cfg *Config) WithDecoder(opts ...DecoderOption) *Config {
+	if len(opts) == 0 {
+		return cfg
+	}
+	return &Config{
+		decOpts: append(append([]DecoderOption{}, cfg.decOpts...), opts...),
+		encOpts: cfg.encOpts,
+		plans:   cfg.plans,
+	}
+}
+
+// WithEncoder returns a copy of cfg with opts added to its encoder options.
+func (cfg *Config) WithEncoder(opts ...EncoderOption) *Config {
+	if len(opts) == 0 {
+		return cfg
+	}
+	return &Config{
+		decOpts: cfg.decOpts,
+		encOpts: append(append([]EncoderOption{}, cfg.encOpts...), opts...),
+		plans:   cfg.plans,
+	}
+}
+
+// NewDecoder reads b and returns a decoder using the options of cfg.
+func (cfg *Config) NewDecoder(b []byte) (*CSVDecoder, error) {
+	return NewCSVDecoder(b, cfg.decOpts...)
+}
+
+// decodePlan returns the cached decode plan of t.
+func (cfg *Config) decodePlan(t reflect.Type) []fieldPlan {
+	if cfg.plans == nil {
+		return newDecodePlan(t)
+	}
+	if plan, ok := cfg.plans.dec.Load(t); ok {
+		return plan.([]fieldPlan)
+	}
+	plan, _ := cfg.plans.dec.LoadOrStore(t, newDecodePlan(t))
+	return plan.([]fieldPlan)
+}
+
+// NewEncoder returns an encoder for v, a struct or a slice of structs, with
+// its header already encoded. The header is only worked out once per type
+// and encoder settings.
+func (cfg *Config) NewEncoder(v reflect.Value) (*CSVEncoder, error) {
+	if cfg.plans == nil {
+		return NewCSVEncoder(v, cfg.encOpts...)
+	}
+	t := v.Type()
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	key := encKey{t: t}
+	if settings, ok := newEncoder(cfg.encOpts...).settings(); ok {
+		key.settings = settings
+	} else {
+		key.cfg = cfg
+	}
+	if plan, ok := cfg.plans.enc.Load(key); ok {
+		return plan.(*CSVEncoder).clone(), nil
+	}
+
+	encoder, err := NewCSVEncoder(v, cfg.encOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if atomic.AddInt64(&cfg.plans.n, 1) <= maxPlans {
+		cfg.plans.enc.Store(key, encoder.clone())
+	}
+	return encoder, nil
+}
+
+// Unmarshal decodes the rows of b into v, a pointer to a slice of structs.
+func (cfg *Config) Unmarshal(b []byte, v interface{}) error {
+	return cfg.UnmarshalReader(bytes.NewReader(b), v)
+}
+
+// UnmarshalReader is like Unmarshal but reads the CSV data from r, stopping
+// early when it goes over the decoder's Limits.
+func (cfg *Config) UnmarshalReader(r io.Reader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+
+	if rv.Elem().Kind() != reflect.Slice {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+
+	if rv.Elem().Type().Elem().Kind() != reflect.Struct {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+
+	decoder, err := NewCSVDecoderFrom(r, cfg.decOpts...)
+	if err != nil {
+		return err
+	}
+	t := rv.Elem().Type().Elem()
+	if err := decoder.usePlan(t, cfg.decodePlan(t)); err != nil {
+		return err
+	}
+
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalRow decodes a single data row of b into v, a pointer to a
+// struct. Row 1 is the first row after the header.
+func (cfg *Config) UnmarshalRow(row int, b []byte, v interface{}) error {
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+
+	if rv.Elem().Kind() != reflect.Struct {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+
+	decoder, err := cfg.NewDecoder(b)
+	if err != nil {
+		return err
+	}
+
+	if row >= len(decoder.Rows) || row < 1 {
+		return fmt.Errorf("csv: Invalid row")
+	}
+	t := rv.Elem().Type()
+	if err := decoder.usePlan(t, cfg.decodePlan(t)); err != nil {
+		return err
+	}
+
+	if err := decoder.decodeInto(row, rv.Elem()); err != nil {
+		return shared.AtLine(&RowError{Row: row, Err: err}, decoder.lines)
+	}
+
+	return nil
+}
+
+// Marshal encodes v, a struct or a slice of structs, with a header row.
+func (cfg *Config) Marshal(v interface{}) ([]byte, error) {
+	val, err := marshalValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, err := cfg.NewEncoder(val)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := encoder.Encode(val)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// MarshalTo writes v like Marshal, but encodes and writes one row at a time
+// instead of building the whole output in memory.
+func (cfg *Config) MarshalTo(w io.Writer, v interface{}) error {
+	val, err := marshalValue(v)
+	if err != nil {
+		return err
+	}
+
+	encoder, err := cfg.NewEncoder(val)
+	if err != nil {
+		return err
+	}
+	return encoder.EncodeTo(w, val)
+}
+
+func marshalValue(v interface{}) (reflect.Value, error) {
+	val := reflect.ValueOf(v)
+
+	if val.Kind() == reflect.Slice {
+		if val.Type().Elem().Kind() != reflect.Struct {
+			return val, fmt.Errorf("csv error: expected a struct or a list of struct\n")
+		}
+	} else if val.Kind() != reflect.Struct {
+		return val, fmt.Errorf("csv error: expected a struct or a list of struct\n")
+	}
+	return val, nil
+}
@@ -0,0 +1,115 @@
+package csv
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type configRow struct {
+	Name  string  `csv:"name"`
+	Qty   int     `csv:"qty,min=0"`
+	Price float64 `csv:"price,format=f,prec=2"`
+	Addr  struct {
+		City string `csv:"city"`
+	} `csv:"addr"`
+}
+
+func TestConfigConcurrent(t *testing.T) {
+	cfg := NewConfig().WithEncoder(OrderColumns("qty")).WithDecoder(WithEmptyRows(KeepEmptyRows))
+	in := make([]configRow, 50)
+	for i := range in {
+		in[i].Name = fmt.Sprintf("n%d", i)
+		in[i].Qty = i
+		in[i].Price = float64(i) / 4
+		in[i].Addr.City = fmt.Sprintf("c%d", i%7)
+	}
+	want, err := cfg.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				b, err := cfg.Marshal(in)
+				if err != nil || string(b) != string(want) {
+					errs <- fmt.Errorf("Marshal: %v\n%s", err, b)
+					return
+				}
+				var out []configRow
+				if err := cfg.Unmarshal(b, &out); err != nil || !reflect.DeepEqual(out, in) {
+					errs <- fmt.Errorf("Unmarshal: %v", err)
+					return
+				}
+				// package-level calls share the default Config
+				if _, err := Marshal(in[g:], FormatInts(16)); err != nil {
+					errs <- err
+					return
+				}
+				var one configRow
+				if err := UnmarshalRow(1, b, &one, DecodeColumns("name")); err != nil || one.Name != "n0" {
+					errs <- fmt.Errorf("UnmarshalRow: %v, %+v", err, one)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConfigPlanReuse(t *testing.T) {
+	cfg := NewConfig()
+	typ := reflect.TypeOf(configRow{})
+	key := func(c *Config) encKey {
+		k := encKey{t: typ}
+		if s, ok := newEncoder(c.encOpts...).settings(); ok {
+			k.settings = s
+		} else {
+			k.cfg = c
+		}
+		return k
+	}
+
+	// configs derived with equal options share their plans
+	a, b := cfg.WithEncoder(FormatInts(16)), cfg.WithEncoder(FormatInts(16))
+	if _, err := a.Marshal([]configRow{{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.plans.enc.Load(key(b)); !ok {
+		t.Error("plan not shared between configs with equal options")
+	}
+	if _, ok := cfg.plans.enc.Load(key(cfg.WithEncoder(FormatInts(8)))); ok {
+		t.Error("plan shared between configs with different options")
+	}
+	if key(cfg.WithEncoder(FormatNumbers(&NumberLocale{}))) == key(cfg) {
+		t.Error("an empty locale has the key of no locale")
+	}
+
+	// a labeler is a function, so its plans stay with its Config
+	label := func(p string) string { return p }
+	c, d := cfg.WithEncoder(LabelHeaders(label)), cfg.WithEncoder(LabelHeaders(label))
+	if _, err := c.Marshal([]configRow{{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.plans.enc.Load(key(d)); ok {
+		t.Error("labeler plan shared between configs")
+	}
+
+	var out []configRow
+	if err := cfg.Unmarshal([]byte("name\nx"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.WithDecoder(WithEmptyRows(KeepEmptyRows)).plans.dec.Load(typ); !ok {
+		t.Error("decode plan not shared")
+	}
+}
@@ -2,12 +2,17 @@ package csv
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xiphoid24/csv/internal/shared"
 )
 
 type InvalidUnmarshalError struct {
@@ -31,18 +36,133 @@ func (e *InvalidUnmarshalError) Error() string {
 	return "csv: Unmarshal(nil " + e.Type.String() + ")"
 }
 
-var EMPTYROW = errors.New("CSV EMPTY ROW")
+var EMPTYROW = shared.EmptyRow
+
+// RowError reports a problem with a single data row. Row is the index of the
+// record in the input, with the header at row 0.
+type RowError = shared.RowError
+
+// RangeError reports a number that does not fit its field, either because
+// it overflows the field's type or because it is outside the field's min or
+// max tag option. Min or Max is set to the violated bound in the latter case.
+type RangeError = shared.RangeError
+
+// ValueError reports a cell that does not convert to the type of its
+// field. Column is the column path, or the column name when decoding a
+// Schema.
+type ValueError struct {
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("csv: %s: invalid value %q: %v", e.Column, e.Value, e.Err)
+}
+
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// The reasons a ValueError gives for cells that are not of their
+// field's kind.
+var (
+	errNumber = errors.New("must be a number")
+	errBool   = errors.New("must be true or false")
+	errTime   = errors.New("must be a time")
+)
+
+// Validator is implemented by types that check their own business rules.
+// Decode calls Validate on every decoded element and reports a failure as a
+// *RowError.
+type Validator = shared.Validator
 
 type CSVDecoder struct {
 	Rdr       *csv.Reader
 	Rows      [][]string
 	HeaderMap map[string]int
 	RowFilled bool
+	EmptyRows EmptyRowPolicy
+	Validate  func(row int, v interface{}) error
+
+	// IntBase is the base integers are parsed in. TrueString and
+	// FalseString are accepted for booleans in addition to the values
+	// understood by strconv.ParseBool.
+	IntBase     int
+	TrueString  string
+	FalseString string
+	Locale      *NumberLocale
+
+	// NullTokens are the cell values that mean "no value". When it is
+	// empty only the empty cell does. Null cells leave fields at their
+	// zero value, so nullable types such as sql.NullString stay invalid.
+	NullTokens []string
+	TimeLayout string
+
+	// Select, when set, limits decoding to the listed dotted column paths.
+	// Other columns are left unconverted.
+	Select   []string
+	selected map[string]bool
+
+	// Labeler returns the header label of a column path. Columns are
+	// found by path first, then by the Labeler's label, then by the
+	// csvlabel tag.
+	Labeler func(path string) string
+
+	// Comma is the field delimiter. It defaults to ','.
+	Comma rune
+
+	// Limits bounds the rows, columns and bytes NewCSVDecoder reads.
+	Limits Limits
+
+	// UnescapeFormulas removes the quote that EscapeFormulas puts in front
+	// of string cells.
+	UnescapeFormulas bool
+
+	// Workers, when greater than one, converts rows on that many
+	// goroutines. The output keeps the input order and the Validate hook
+	// must be safe for concurrent use.
+	Workers int
+
+	// lines holds the input line each of Rows starts on.
+	lines []int
+
+	// plan lists the fields of planType, and columns the header column of
+	// each, or -1 when it is not decoded.
+	plan     []fieldPlan
+	planType reflect.Type
+	columns  []int
+
+	// names, when set, renames column paths in conversion errors.
+	names map[string]string
 }
 
-func NewCSVDecoder(b []byte) (*CSVDecoder, error) {
-	c := new(CSVDecoder)
-	c.Rdr = csv.NewReader(bytes.NewBuffer(b))
+func NewCSVDecoder(b []byte, opts ...DecoderOption) (*CSVDecoder, error) {
+	return NewCSVDecoderFrom(bytes.NewReader(b), opts...)
+}
+
+// NewCSVDecoderFrom is like NewCSVDecoder but reads the records from r. It
+// stops reading as soon as the input goes over the decoder's Limits, so r
+// can be an upload that is never held in memory whole.
+func NewCSVDecoderFrom(r io.Reader, opts ...DecoderOption) (*CSVDecoder, error) {
+	c := &CSVDecoder{IntBase: 10}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if len(c.Select) > 0 {
+		c.selected = make(map[string]bool, len(c.Select))
+		for _, col := range c.Select {
+			c.selected[col] = true
+		}
+	}
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	c.Rdr = csv.NewReader(shared.LimitReader(r, c.Limits))
+	if c.Comma != 0 {
+		c.Rdr.Comma = c.Comma
+	}
 	for {
 		row, err := c.Rdr.Read()
 		if err == io.EOF {
@@ -51,7 +171,12 @@ func NewCSVDecoder(b []byte) (*CSVDecoder, error) {
 		if err != nil {
 			return nil, err
 		}
+		if err := shared.CheckRow(c.Limits, len(c.Rows), row); err != nil {
+			return nil, err
+		}
+		line, _ := c.Rdr.FieldPos(0)
 		c.Rows = append(c.Rows, row)
+		c.lines = append(c.lines, line)
 	}
 	if len(c.Rows) < 1 {
 		return nil, fmt.Errorf("csv: error reading rows")
@@ -65,59 +190,22 @@ func NewCSVDecoder(b []byte) (*CSVDecoder, error) {
 	return c, nil
 }
 
-func Unmarshal(b []byte, v interface{}) error {
-
-	rv := reflect.ValueOf(v)
-	if rv.Kind() != reflect.Ptr || rv.IsNil() {
-		return &InvalidUnmarshalError{reflect.TypeOf(v)}
-	}
-
-	if rv.Elem().Kind() != reflect.Slice {
-		return &InvalidUnmarshalError{reflect.TypeOf(v)}
-	}
-
-	if rv.Elem().Type().Elem().Kind() != reflect.Struct {
-		fmt.Println(reflect.TypeOf(v))
-		return &InvalidUnmarshalError{reflect.TypeOf(v)}
-	}
-
-	decoder, err := NewCSVDecoder(b)
-	if err != nil {
-		return err
-	}
-
-	if err := decoder.Decode(v); err != nil {
-		return err
-	}
-	return nil
+// Unmarshal decodes the rows of b into v, a pointer to a slice of structs.
+// It uses the default Config with opts added.
+func Unmarshal(b []byte, v interface{}, opts ...DecoderOption) error {
+	return defaultConfig.WithDecoder(opts...).Unmarshal(b, v)
 }
 
-func UnmarshalRow(row int, b []byte, v interface{}) error {
-
-	rv := reflect.ValueOf(v)
-	if rv.Kind() != reflect.Ptr || rv.IsNil() {
-		return &InvalidUnmarshalError{reflect.TypeOf(v)}
-	}
-
-	if rv.Elem().Kind() != reflect.Struct {
-		fmt.Println(reflect.TypeOf(v))
-		return &InvalidUnmarshalError{reflect.TypeOf(v)}
-	}
-
-	decoder, err := NewCSVDecoder(b)
-	if err != nil {
-		return err
-	}
-
-	if row > len(decoder.Rows) || row < 1 {
-		return fmt.Errorf("csv: Invalid row")
-	}
-
-	if err := decoder.DecodeRow(row, "", rv.Elem()); err != nil {
-		return err
-	}
+// UnmarshalReader is like Unmarshal but reads the CSV data from r,
+// stopping early when it goes over the Limits set with WithLimits.
+func UnmarshalReader(r io.Reader, v interface{}, opts ...DecoderOption) error {
+	return defaultConfig.WithDecoder(opts...).UnmarshalReader(r, v)
+}
 
-	return nil
+// UnmarshalRow decodes a single data row of b into v, a pointer to a
+// struct. It uses the default Config with opts added.
+func UnmarshalRow(row int, b []byte, v interface{}, opts ...DecoderOption) error {
+	return defaultConfig.WithDecoder(opts...).UnmarshalRow(row, b, v)
 }
 
 func (c *CSVDecoder) GetHeader() []string {
@@ -128,10 +216,10 @@ func (c *CSVDecoder) GetHeader() []string {
 }
 
 func (c *CSVDecoder) GetFieldInRow(r, f int) string {
-	if len(c.Rows) < r {
+	if len(c.Rows) <= r {
 		return ""
 	}
-	if len(c.Rows[r]) < f {
+	if len(c.Rows[r]) <= f {
 		return ""
 	}
 	return c.Rows[r][f]
@@ -147,21 +235,57 @@ func (c *CSVDecoder) Decode(ptr interface{}) error {
 
 	// get type of single element
 	strctTyp := val.Type().Elem()
+	if c.planType != strctTyp {
+		if err := c.usePlan(strctTyp, newDecodePlan(strctTyp)); err != nil {
+			return err
+		}
+	}
+
+	if c.Workers > 1 {
+		return c.decodeParallel(val, strctTyp)
+	}
 
 	for rowNum := 1; rowNum < len(c.Rows); rowNum++ {
-		c.RowFilled = false
-		strct := reflect.Indirect(reflect.New(strctTyp))
-		if err := c.DecodeRow(rowNum, "", strct); err != nil {
+		strct, keep, err := c.decodeElem(rowNum, strctTyp)
+		if err != nil {
 			return err
 		}
-
-		if c.RowFilled {
+		if keep {
 			val.Set(reflect.Append(val, strct))
 		}
 	}
 	return nil
 }
 
+// decodeElem decodes one row into a new element and applies the empty-row
+// policy and validation. keep is false for skipped empty rows.
+func (c *CSVDecoder) decodeElem(rowNum int, strctTyp reflect.Type) (reflect.Value, bool, error) {
+	c.RowFilled = false
+	strct := reflect.Indirect(reflect.New(strctTyp))
+	if err := c.decodeInto(rowNum, strct); err != nil {
+		return strct, false, shared.AtLine(&RowError{Row: rowNum, Err: err}, c.lines)
+	}
+
+	if !c.RowFilled {
+		switch c.EmptyRows {
+		case SkipEmptyRows:
+			return strct, false, nil
+		case ErrorEmptyRows:
+			return strct, false, shared.AtLine(&RowError{Row: rowNum, Err: EMPTYROW}, c.lines)
+		}
+	}
+	if err := c.validate(rowNum, strct); err != nil {
+		return strct, false, shared.AtLine(err, c.lines)
+	}
+	return strct, true, nil
+}
+
+// validate runs the element's own Validate method, if it has one, followed
+// by the decoder's Validate hook.
+func (c *CSVDecoder) validate(rowNum int, strct reflect.Value) error {
+	return shared.Validate(rowNum, strct.Addr().Interface(), c.Validate)
+}
+
 func (c *CSVDecoder) DecodeRow(rowNum int, start string, strct reflect.Value) error {
 
 	for fieldNum := 0; fieldNum < strct.NumField(); fieldNum++ {
@@ -170,7 +294,7 @@ func (c *CSVDecoder) DecodeRow(rowNum int, start string, strct reflect.Value) er
 		fld := strct.Field(fieldNum)
 		name := strctTyp.Field(fieldNum).Name
 
-		tag := strctTyp.Field(fieldNum).Tag.Get("csv")
+		tag, opts := parseTag(strctTyp.Field(fieldNum).Tag.Get("csv"))
 		if tag == "-" {
 			continue
 		}
@@ -179,7 +303,7 @@ func (c *CSVDecoder) DecodeRow(rowNum int, start string, strct reflect.Value) er
 			tag = name
 		}
 
-		if fld.Kind() == reflect.Struct {
+		if fld.Kind() == reflect.Struct && !isScalarStruct(fld.Type()) {
 			st := reflect.Indirect(fld)
 			if err := c.DecodeRow(rowNum, start+tag+".", st); err != nil {
 				return err
@@ -188,44 +312,221 @@ func (c *CSVDecoder) DecodeRow(rowNum int, start string, strct reflect.Value) er
 			continue
 		}
 
-		columnNum, ok := c.HeaderMap[start+tag]
-		if !ok {
+		if c.selected != nil && !c.selected[start+tag] {
 			continue
 		}
-		csvVal := c.GetFieldInRow(rowNum, columnNum)
-		if csvVal == "" {
+		columnNum, ok := c.column(start+tag, strctTyp.Field(fieldNum).Tag.Get("csvlabel"))
+		if !ok {
 			continue
 		}
-		c.RowFilled = true
-		switch fld.Kind() {
-		case reflect.String:
-			fld.SetString(csvVal)
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			in, err := strconv.ParseInt(csvVal, 10, 64)
-			if err != nil {
-				return fmt.Errorf("csv: %s +  Must be a a number", name)
-			}
-			fld.SetInt(in)
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			u, err := strconv.ParseUint(csvVal, 10, 64)
-			if err != nil {
-				return fmt.Errorf("csv: %s +  Must be a a number", name)
-			}
-			fld.SetUint(u)
-		case reflect.Float32, reflect.Float64:
-			f, err := strconv.ParseFloat(csvVal, 64)
-			if err != nil {
-				return fmt.Errorf("csv: %s +  Must be a a number", name)
+		if err := c.decodeField(rowNum, columnNum, fld, start+tag, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeField converts the cell in column columnNum of a row into the
+// scalar field fld, whose column path is path.
+func (c *CSVDecoder) decodeField(rowNum, columnNum int, fld reflect.Value, path string, opts tagOptions) error {
+	csvVal := c.GetFieldInRow(rowNum, columnNum)
+	if c.isNull(csvVal) {
+		if isScanner(fld.Type()) {
+			fld.Set(reflect.Zero(fld.Type()))
+		}
+		return nil
+	}
+	if csvVal == "" {
+		// with explicit null tokens an empty cell is an empty value
+		if fld.Kind() == reflect.Struct {
+			if val, valid, ok := nullFields(fld); ok && val.Kind() == reflect.String {
+				val.SetString("")
+				valid.SetBool(true)
 			}
-			fld.SetFloat(f)
-		case reflect.Bool:
-			b, err := strconv.ParseBool(csvVal)
-			if err != nil {
-				return fmt.Errorf("csv: %s +  Must be either true or false", name)
+		}
+		return nil
+	}
+	c.RowFilled = true
+	if err := c.setValue(fld, csvVal, opts); err != nil {
+		if n, ok := c.names[path]; ok {
+			path = n
+		}
+		if re, ok := err.(*RangeError); ok {
+			re.Column = path
+			return re
+		}
+		return &ValueError{Column: path, Value: csvVal, Err: err}
+	}
+	return nil
+}
+
+// setValue converts csvVal into the scalar field fld, honouring the base,
+// true and false tag options.
+func (c *CSVDecoder) setValue(fld reflect.Value, csvVal string, opts tagOptions) error {
+	if fld.Type() == timeType {
+		t, err := time.Parse(timeLayout(c.TimeLayout, opts), csvVal)
+		if err != nil {
+			return errTime
+		}
+		fld.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch fld.Kind() {
+	case reflect.Struct:
+		if val, valid, ok := nullFields(fld); ok {
+			if err := c.setValue(val, csvVal, opts); err != nil {
+				return err
 			}
-			fld.SetBool(b)
+			valid.SetBool(true)
+			return nil
+		}
+		if err := fld.Addr().Interface().(sql.Scanner).Scan(csvVal); err != nil {
+			return err
+		}
+	case reflect.String:
+		if c.UnescapeFormulas {
+			csvVal = shared.UnescapeFormula(csvVal)
+		}
+		fld.SetString(csvVal)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		base, err := opts.Base(c.IntBase)
+		if err != nil {
+			return err
+		}
+		s, percent, err := c.number(csvVal, opts)
+		if err != nil {
+			return err
+		}
+		in, err := strconv.ParseInt(s, base, fld.Type().Bits())
+		if shared.IsRangeErr(err) {
+			return &RangeError{Value: csvVal, Type: fld.Type()}
+		}
+		if err != nil || percent {
+			return errNumber
+		}
+		fld.SetInt(in)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		base, err := opts.Base(c.IntBase)
+		if err != nil {
+			return err
+		}
+		s, percent, err := c.number(csvVal, opts)
+		if err != nil {
+			return err
+		}
+		u, err := strconv.ParseUint(s, base, fld.Type().Bits())
+		if shared.IsRangeErr(err) {
+			return &RangeError{Value: csvVal, Type: fld.Type()}
+		}
+		if err != nil || percent {
+			return errNumber
+		}
+		fld.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		s, percent, err := c.number(csvVal, opts)
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(s, fld.Type().Bits())
+		if shared.IsRangeErr(err) {
+			return &RangeError{Value: csvVal, Type: fld.Type()}
+		}
+		if err != nil {
+			return errNumber
 		}
+		if percent {
+			f /= 100
+		}
+		fld.SetFloat(f)
+	case reflect.Bool:
+		b, err := c.parseBool(csvVal, opts)
+		if err != nil {
+			return err
+		}
+		fld.SetBool(b)
 	}
+	return checkBounds(fld, csvVal, opts)
+}
 
-	return nil
+// checkBounds enforces the min and max tag options on a decoded number.
+func checkBounds(fld reflect.Value, csvVal string, opts tagOptions) error {
+	min, _ := opts.Get("min")
+	max, _ := opts.Get("max")
+	return shared.CheckBounds(fld, csvVal, min, max)
+}
+
+// column finds the header column of a field by its path or its labels.
+func (c *CSVDecoder) column(path, label string) (int, bool) {
+	if i, ok := c.HeaderMap[path]; ok {
+		return i, true
+	}
+	if c.Labeler != nil {
+		if i, ok := c.HeaderMap[c.Labeler(path)]; ok {
+			return i, true
+		}
+	}
+	if label == "" {
+		return 0, false
+	}
+	i, ok := c.HeaderMap[label]
+	return i, ok
+}
+
+// Line returns the input line row starts on, counting from 1, or 0 when it
+// is not known.
+func (c *CSVDecoder) Line(row int) int {
+	if row < 0 || row >= len(c.lines) {
+		return 0
+	}
+	return c.lines[row]
+}
+
+// isNull reports whether csvVal is one of the decoder's null tokens.
+func (c *CSVDecoder) isNull(csvVal string) bool {
+	if len(c.NullTokens) == 0 {
+		return csvVal == ""
+	}
+	for _, tok := range c.NullTokens {
+		if csvVal == tok {
+			return true
+		}
+	}
+	return false
+}
+
+// number strips locale formatting from a numeric cell when the decoder or
+// the field has a locale, or the field has the percent tag option. It
+// reports whether the value was a percentage.
+func (c *CSVDecoder) number(csvVal string, opts tagOptions) (string, bool, error) {
+	loc, err := numberLocale(c.Locale, opts)
+	if err != nil {
+		return "", false, err
+	}
+	if loc == nil && !opts.Has("percent") {
+		return csvVal, false, nil
+	}
+	return normalizeNumber(csvVal, loc)
+}
+
+func (c *CSVDecoder) parseBool(csvVal string, opts tagOptions) (bool, error) {
+	t, f := c.TrueString, c.FalseString
+	if s, ok := opts.Get("true"); ok {
+		t = s
+	}
+	if s, ok := opts.Get("false"); ok {
+		f = s
+	}
+	switch {
+	case t != "" && strings.EqualFold(csvVal, t):
+		return true, nil
+	case f != "" && strings.EqualFold(csvVal, f):
+		return false, nil
+	}
+	b, err := strconv.ParseBool(csvVal)
+	if err != nil {
+		return false, errBool
+	}
+	return b, nil
 }
@@ -0,0 +1,191 @@
+package csv
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEmptyRows(t *testing.T) {
+	type row struct {
+		Name string
+		Qty  int
+	}
+	// the blank line is dropped by the reader, the ",," record is empty
+	in := []byte("Name,Qty\na,1\n\n,\nb,2")
+	tests := []struct {
+		policy EmptyRowPolicy
+		want   []row
+		line   int
+	}{
+		{SkipEmptyRows, []row{{"a", 1}, {"b", 2}}, 0},
+		{KeepEmptyRows, []row{{"a", 1}, {}, {"b", 2}}, 0},
+		{ErrorEmptyRows, nil, 4},
+	}
+	for _, tt := range tests {
+		var got []row
+		err := Unmarshal(in, &got, WithEmptyRows(tt.policy))
+		if tt.line > 0 {
+			var re *RowError
+			if !errors.As(err, &re) || !errors.Is(err, EMPTYROW) || re.Row != 2 || re.Line != tt.line {
+				t.Errorf("policy %d: got %v, want EMPTYROW at row 2, line %d", tt.policy, err, tt.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("policy %d: %v", tt.policy, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("policy %d: got %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestRowErrorLine(t *testing.T) {
+	type row struct {
+		Note string
+		Qty  int
+	}
+	in := []byte("Note,Qty\n\"two\nlines\",1\n\nx,-1")
+	var got []row
+	err := Unmarshal(in, &got, WithValidator(func(n int, v interface{}) error {
+		if v.(*row).Qty < 0 {
+			return errors.New("negative")
+		}
+		return nil
+	}))
+	var re *RowError
+	if !errors.As(err, &re) || re.Row != 2 || re.Line != 5 {
+		t.Fatalf("got %v, want row 2 at line 5", err)
+	}
+	if want := "csv: row 2 (line 5): negative"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+}
+
+type checked struct {
+	Name string
+	Qty  int
+}
+
+func (c *checked) Validate() error {
+	if c.Qty < 0 {
+		return errors.New("negative qty")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	noB := func(row int, v interface{}) error {
+		if v.(*checked).Name == "b" {
+			return errors.New("no b")
+		}
+		return nil
+	}
+	tests := []struct {
+		in   string
+		hook func(int, interface{}) error
+		row  int
+		err  string
+	}{
+		{"Name,Qty\na,1\nb,2", nil, 0, ""},
+		{"Name,Qty\na,1\nb,-2", nil, 2, "negative qty"},
+		{"Name,Qty\na,1\nb,2", noB, 2, "no b"},
+		// the element's own method runs before the hook
+		{"Name,Qty\nb,-1", noB, 1, "negative qty"},
+	}
+	for _, tt := range tests {
+		var got []checked
+		err := Unmarshal([]byte(tt.in), &got, WithValidator(tt.hook))
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%q: %v", tt.in, err)
+			}
+			continue
+		}
+		var re *RowError
+		if !errors.As(err, &re) || re.Row != tt.row || re.Err.Error() != tt.err {
+			t.Errorf("%q: got %v, want %q at row %d", tt.in, err, tt.err, tt.row)
+		}
+	}
+}
+
+func TestRangeErrors(t *testing.T) {
+	type row struct {
+		I8  int8    `csv:"i8"`
+		U8  uint8   `csv:"u8"`
+		F32 float32 `csv:"f32"`
+		Qty int     `csv:"qty,min=1,max=10"`
+		Pct float64 `csv:"pct,min=0,max=1"`
+	}
+	tests := []struct {
+		in       string
+		col      string
+		min, max string
+	}{
+		{"1,1,1,5,0.5", "", "", ""},
+		{"300,1,1,5,0.5", "i8", "", ""},
+		{"-129,1,1,5,0.5", "i8", "", ""},
+		{"1,256,1,5,0.5", "u8", "", ""},
+		{"1,1,1e39,5,0.5", "f32", "", ""},
+		{"1,1,1,0,0.5", "qty", "1", ""},
+		{"1,1,1,11,0.5", "qty", "", "10"},
+		{"1,1,1,5,1.5", "pct", "", "1"},
+		{"1,1,1,5,NaN", "pct", "0", ""},
+	}
+	for _, tt := range tests {
+		var out []row
+		err := Unmarshal([]byte("i8,u8,f32,qty,pct\n"+tt.in), &out)
+		if tt.col == "" {
+			if err != nil {
+				t.Errorf("%q: %v", tt.in, err)
+			}
+			continue
+		}
+		var re *RangeError
+		if !errors.As(err, &re) || re.Column != tt.col || re.Min != tt.min || re.Max != tt.max {
+			t.Errorf("%q: got %v, want a range error on %s", tt.in, err, tt.col)
+		}
+	}
+}
+
+func TestConversionRowError(t *testing.T) {
+	type row struct {
+		Name string `csv:"name"`
+		Qty  int    `csv:"qty"`
+	}
+	in := []byte("name,qty\na,1\n\nb,x\nc,3")
+	want := `csv: row 2 (line 4): csv: qty: invalid value "x": must be a number`
+
+	var out []row
+	errs := map[string]error{
+		"Unmarshal":         Unmarshal(in, &out),
+		"Unmarshal Workers": Unmarshal(in, &out, Workers(2)),
+		"UnmarshalRow":      UnmarshalRow(2, in, &row{}),
+	}
+	for name, err := range errs {
+		var re *RowError
+		var ve *ValueError
+		if !errors.As(err, &re) || re.Row != 2 || re.Line != 4 || err.Error() != want {
+			t.Errorf("%s: error = %v, want %s", name, err, want)
+		}
+		if !errors.As(err, &ve) || ve.Column != "qty" || ve.Value != "x" {
+			t.Errorf("%s: error = %v, want a *ValueError for qty", name, err)
+		}
+	}
+}
+
+func TestUnmarshalReader(t *testing.T) {
+	type row struct {
+		Name string `csv:"name"`
+	}
+	var out []row
+	if err := UnmarshalReader(strings.NewReader("name\na\nb"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := []row{{"a"}, {"b"}}; !reflect.DeepEqual(out, want) {
+		t.Errorf("UnmarshalReader = %v, want %v", out, want)
+	}
+}
@@ -1,39 +1,82 @@
 package csv
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"database/sql/driver"
 	"fmt"
+	"io"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xiphoid24/csv/internal/shared"
 )
 
 type CSVEncoder struct {
 	HeaderFields map[string][]string
 	Rows         [][]byte
 	RowCache     []string
-}
 
-func Marshal(v interface{}) ([]byte, error) {
-	val := reflect.ValueOf(v)
+	// FloatFormat is the strconv.FormatFloat format ('f', 'e', 'g', ...)
+	// used for floats. When it is zero floats are written with %v and
+	// FloatPrecision is ignored.
+	FloatFormat    byte
+	FloatPrecision int
+	IntBase        int
+	TrueString     string
+	FalseString    string
+	Locale         *NumberLocale
 
-	if val.Kind() == reflect.Slice {
-		if val.Type().Elem().Kind() != reflect.Struct {
-			return nil, fmt.Errorf("csv error: expected a struct or a list of struct\n")
-		}
-	} else if val.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("csv error: expected a struct or a list of struct\n")
-	}
+	// NullToken is written for invalid nullable values such as an
+	// sql.NullInt64 with Valid set to false.
+	NullToken  string
+	TimeLayout string
 
-	encoder, err := NewCSVEncoder(val)
-	if err != nil {
-		return nil, err
-	}
+	// Comma is the field separator when it is not a comma.
+	Comma rune
 
-	b, err := encoder.Encode(val)
-	if err != nil {
-		return nil, err
-	}
-	return b, nil
+	// Gzip compresses the output.
+	Gzip bool
+
+	// EscapeFormulas prefixes string cells that a spreadsheet would run as
+	// a formula with a single quote.
+	EscapeFormulas bool
+
+	// Columns lists header columns, by their dotted path, that are written
+	// first and in the given order.
+	Columns []string
+	// Select, when set, restricts the output to the listed columns, in
+	// the given order.
+	Select []string
+
+	// Labeler returns the header text for a column path. When it is nil or
+	// returns "" the csvlabel tag is used, falling back to the path.
+	Labeler func(path string) string
+
+	orderTags map[int]int
+	labels    map[int]string
+	order     []int
+	width     int
+}
+
+// Marshal encodes v, a struct or a slice of structs, with a header row. It
+// uses the default Config with opts added.
+//
+// Unmarshal reads the output back unchanged, with two exceptions: "\r\n"
+// inside a value reads back as "\n", and an element whose cells are all
+// empty is only kept with KeepEmptyRows.
+func Marshal(v interface{}, opts ...EncoderOption) ([]byte, error) {
+	return defaultConfig.WithEncoder(opts...).Marshal(v)
+}
+
+// MarshalTo writes v to w like Marshal, one row at a time. It uses the
+// default Config with opts added.
+func MarshalTo(w io.Writer, v interface{}, opts ...EncoderOption) error {
+	return defaultConfig.WithEncoder(opts...).MarshalTo(w, v)
 }
 
 func (c *CSVEncoder) String() string {
@@ -48,18 +91,60 @@ func (c *CSVEncoder) String() string {
 	return s
 }
 
-func NewCSVEncoder(v reflect.Value) (*CSVEncoder, error) {
-	exporter := &CSVEncoder{
-		HeaderFields: map[string][]string{},
-		Rows:         [][]byte{},
-	}
-
+func NewCSVEncoder(v reflect.Value, opts ...EncoderOption) (*CSVEncoder, error) {
+	exporter := newEncoder(opts...)
 	if err := exporter.EncodeHeader(v); err != nil {
 		return nil, err
 	}
 	return exporter, nil
 }
 
+// NewValueEncoder returns an encoder without a header, for callers that
+// format loose values with FormatValue and write the records themselves.
+// They apply Comma and Gzip when writing. OrderColumns, SelectColumns and
+// LabelHeaders shape a struct header and are rejected.
+func NewValueEncoder(opts ...EncoderOption) (*CSVEncoder, error) {
+	c := newEncoder(opts...)
+	if len(c.Columns) > 0 || len(c.Select) > 0 || c.Labeler != nil {
+		return nil, fmt.Errorf("csv: column order, selection and labels need a struct header")
+	}
+	if c.Comma != 0 && !validDelim(c.Comma) {
+		return nil, fmt.Errorf("csv: invalid delimiter %q", c.Comma)
+	}
+	return c, nil
+}
+
+// newEncoder returns an encoder with the default settings and opts
+// applied, before any header is encoded.
+func newEncoder(opts ...EncoderOption) *CSVEncoder {
+	c := &CSVEncoder{
+		HeaderFields:   map[string][]string{},
+		Rows:           [][]byte{},
+		FloatPrecision: -1,
+		IntBase:        10,
+		TrueString:     "true",
+		FalseString:    "false",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// settings renders the settings of an encoder fresh from newEncoder as a
+// comparable key. ok is false when they hold a function.
+func (c *CSVEncoder) settings() (key string, ok bool) {
+	if c.Labeler != nil {
+		return "", false
+	}
+	e := *c
+	var loc NumberLocale
+	if e.Locale != nil {
+		loc, e.Locale = *e.Locale, nil
+	}
+	return fmt.Sprintf("%#v %v %#v", e, c.Locale != nil, loc), true
+}
+
 func (c *CSVEncoder) EncodeHeader(v reflect.Value) error {
 	if v.Kind() == reflect.Slice {
 		v = reflect.Zero(v.Type().Elem())
@@ -68,12 +153,28 @@ func (c *CSVEncoder) EncodeHeader(v reflect.Value) error {
 	if v.Kind() != reflect.Struct {
 		return fmt.Errorf("csv error: expected a struct or a list of struct\n")
 	}
+	if c.Comma != 0 && !validDelim(c.Comma) {
+		return fmt.Errorf("csv: invalid delimiter %q", c.Comma)
+	}
 	c.RowCache = []string{}
+	c.orderTags = map[int]int{}
+	c.labels = map[int]string{}
 	if err := c.encodeHeader(v, ""); err != nil {
 		return err
 	}
 
-	c.Rows = append(c.Rows, []byte(strings.Join(c.RowCache, ",")))
+	order, err := c.columnOrder(c.RowCache)
+	if err != nil {
+		return err
+	}
+	c.order = order
+	c.width = len(c.RowCache)
+
+	header := make([]string, len(c.RowCache))
+	for i, path := range c.RowCache {
+		header[i] = c.label(i, path)
+	}
+	c.Rows = append(c.Rows, shared.JoinRow(c.permute(header), c.Comma))
 	return nil
 }
 
@@ -82,7 +183,7 @@ func (c *CSVEncoder) encodeHeader(strctVal reflect.Value, start string) error {
 	strctTyp := strctVal.Type()
 
 	for fieldNum := 0; fieldNum < strctVal.NumField(); fieldNum++ {
-		tag := strctTyp.Field(fieldNum).Tag.Get("csv")
+		tag, opts := parseTag(strctTyp.Field(fieldNum).Tag.Get("csv"))
 		if tag == "-" {
 			continue
 		}
@@ -96,36 +197,115 @@ func (c *CSVEncoder) encodeHeader(strctVal reflect.Value, start string) error {
 		switch fld.Kind() {
 		case reflect.Struct:
 			c.HeaderFields[start] = append(c.HeaderFields[start], name)
+			if isScalarStruct(fld.Type()) {
+				if err := c.addColumn(start+tag, opts, strctTyp.Field(fieldNum).Tag.Get("csvlabel")); err != nil {
+					return err
+				}
+				continue
+			}
 			if err := c.encodeHeader(reflect.Indirect(fld), start+tag+"."); err != nil {
 				return err
 			}
 		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.Bool:
 			c.HeaderFields[start] = append(c.HeaderFields[start], name)
-			c.RowCache = append(c.RowCache, start+tag)
+			if err := c.addColumn(start+tag, opts, strctTyp.Field(fieldNum).Tag.Get("csvlabel")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *CSVEncoder) addColumn(path string, opts tagOptions, label string) error {
+	if label != "" {
+		c.labels[len(c.RowCache)] = label
+	}
+	if opts.Has("order") {
+		n, err := opts.Int("order", 0)
+		if err != nil {
+			return err
 		}
+		c.orderTags[len(c.RowCache)] = n
 	}
+	c.RowCache = append(c.RowCache, path)
 	return nil
 }
 
 func (c *CSVEncoder) Encode(v reflect.Value) ([]byte, error) {
 	if v.Kind() == reflect.Struct {
-		if err := c.EncodeRow(v, ""); err != nil {
+		if err := c.encodeCells(v); err != nil {
 			return nil, err
 		}
-		return bytes.Join(c.Rows, []byte("\n")), nil
+		c.Rows = append(c.Rows, shared.JoinRow(c.permute(c.RowCache), c.Comma))
+		return c.output(bytes.Join(c.Rows, []byte("\n")))
 	}
 
 	for i := 0; i < v.Len(); i++ {
-
-		strctVal := v.Index(i)
-		c.RowCache = []string{}
-		if err := c.EncodeRow(strctVal, ""); err != nil {
+		if err := c.encodeCells(v.Index(i)); err != nil {
 			return nil, err
 		}
-		c.Rows = append(c.Rows, []byte(strings.Join(c.RowCache, ",")))
+		c.Rows = append(c.Rows, shared.JoinRow(c.permute(c.RowCache), c.Comma))
+	}
+
+	return c.output(bytes.Join(c.Rows, []byte("\n")))
+}
+
+// output compresses the encoded rows when Gzip is set.
+func (c *CSVEncoder) output(b []byte) ([]byte, error) {
+	if !c.Gzip {
+		return b, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeTo writes the rows encoded so far, normally just the header,
+// followed by the rows of v, a struct or a slice of structs. Rows are
+// written to w as they are encoded instead of being kept in Rows, and
+// compressed on the fly when Gzip is set.
+func (c *CSVEncoder) EncodeTo(w io.Writer, v reflect.Value) error {
+	if !c.Gzip {
+		return c.encodeTo(w, v)
+	}
+	zw := gzip.NewWriter(w)
+	if err := c.encodeTo(zw, v); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (c *CSVEncoder) encodeTo(w io.Writer, v reflect.Value) error {
+	bw := bufio.NewWriter(w)
+	bw.Write(bytes.Join(c.Rows, []byte("\n")))
+
+	write := func(strctVal reflect.Value) error {
+		if err := c.encodeCells(strctVal); err != nil {
+			return err
+		}
+		bw.WriteByte('\n')
+		_, err := bw.Write(shared.JoinRow(c.permute(c.RowCache), c.Comma))
+		return err
 	}
 
-	return bytes.Join(c.Rows, []byte("\n")), nil
+	if v.Kind() == reflect.Struct {
+		if err := write(v); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := write(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
 }
 
 func (c *CSVEncoder) EncodeRow(strctVal reflect.Value, start string) error {
@@ -135,7 +315,7 @@ func (c *CSVEncoder) EncodeRow(strctVal reflect.Value, start string) error {
 	for _, field := range c.HeaderFields[start] {
 		fld := strctVal.FieldByName(field)
 		fldTyp, ok := strctVal.Type().FieldByName(field)
-		tag := fldTyp.Tag.Get("csv")
+		tag, opts := parseTag(fldTyp.Tag.Get("csv"))
 		if !ok {
 			return fmt.Errorf("csv error: failed to find struct field\n")
 		}
@@ -146,12 +326,193 @@ func (c *CSVEncoder) EncodeRow(strctVal reflect.Value, start string) error {
 
 		switch fld.Kind() {
 		case reflect.Struct:
+			if isScalarStruct(fld.Type()) {
+				s, err := c.formatValue(fld, opts)
+				if err != nil {
+					return fmt.Errorf("csv: %s: %v", name, err)
+				}
+				c.RowCache = append(c.RowCache, s)
+				continue
+			}
 			if err := c.EncodeRow(reflect.Indirect(fld), start+tag+"."); err != nil {
 				return err
 			}
 		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.Bool:
-			c.RowCache = append(c.RowCache, fmt.Sprintf("%v", fld.Interface()))
+			s, err := c.formatValue(fld, opts)
+			if err != nil {
+				return fmt.Errorf("csv: %s: %v", name, err)
+			}
+			c.RowCache = append(c.RowCache, s)
 		}
 	}
 	return nil
 }
+
+// clone copies an encoder that has only encoded its header, so the copy
+// can encode rows without touching the original.
+func (c *CSVEncoder) clone() *CSVEncoder {
+	e := *c
+	e.HeaderFields = make(map[string][]string, len(c.HeaderFields))
+	for k, v := range c.HeaderFields {
+		e.HeaderFields[k] = v
+	}
+	e.Rows = [][]byte{c.Rows[0]}
+	e.RowCache = append([]string{}, c.RowCache...)
+	return &e
+}
+
+// label returns the header text written for the i-th column.
+func (c *CSVEncoder) label(i int, path string) string {
+	if c.Labeler != nil {
+		if l := c.Labeler(path); l != "" {
+			return l
+		}
+	}
+	if l, ok := c.labels[i]; ok {
+		return l
+	}
+	return path
+}
+
+// formatValue renders a scalar field using the encoder settings, overridden
+// by the field's format, prec, base, true and false tag options.
+func (c *CSVEncoder) formatValue(fld reflect.Value, opts tagOptions) (string, error) {
+	if fld.Type() == timeType {
+		return fld.Interface().(time.Time).Format(timeLayout(c.TimeLayout, opts)), nil
+	}
+
+	switch fld.Kind() {
+	case reflect.Struct:
+		if val, valid, ok := nullFields(fld); ok {
+			if !valid.Bool() {
+				return c.NullToken, nil
+			}
+			return c.formatValue(val, opts)
+		}
+		v, err := fld.Interface().(driver.Valuer).Value()
+		if err != nil {
+			return "", err
+		}
+		switch v := v.(type) {
+		case nil:
+			return c.NullToken, nil
+		case []byte:
+			return c.escape(string(v)), nil
+		case time.Time:
+			return v.Format(timeLayout(c.TimeLayout, opts)), nil
+		case string:
+			return c.escape(v), nil
+		}
+		return fmt.Sprintf("%v", v), nil
+	case reflect.String:
+		return c.escape(fld.String()), nil
+	case reflect.Float32, reflect.Float64:
+		format, prec := c.FloatFormat, c.FloatPrecision
+		if f, ok := opts.Get("format"); ok {
+			if len(f) != 1 {
+				return "", fmt.Errorf("invalid format option %q", f)
+			}
+			format = f[0]
+		}
+		if opts.Has("prec") {
+			var err error
+			if prec, err = opts.Int("prec", prec); err != nil {
+				return "", err
+			}
+			if format == 0 {
+				format = 'f'
+			}
+		}
+		var s string
+		switch {
+		case opts.Has("percent") && (format == 0 || format == 'f' && prec < 0):
+			s = shiftDecimal(strconv.FormatFloat(fld.Float(), 'f', -1, fld.Type().Bits()), 2)
+		case opts.Has("percent"):
+			s = strconv.FormatFloat(fld.Float()*100, format, prec, fld.Type().Bits())
+		case format == 0:
+			s = fmt.Sprintf("%v", fld.Interface())
+		default:
+			s = strconv.FormatFloat(fld.Float(), format, prec, fld.Type().Bits())
+		}
+		return c.localize(s, opts)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		base, err := opts.Base(c.IntBase)
+		if err != nil {
+			return "", err
+		}
+		if base != 10 {
+			return strconv.FormatInt(fld.Int(), base), nil
+		}
+		return c.localize(strconv.FormatInt(fld.Int(), base), opts)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		base, err := opts.Base(c.IntBase)
+		if err != nil {
+			return "", err
+		}
+		if base != 10 {
+			return strconv.FormatUint(fld.Uint(), base), nil
+		}
+		return c.localize(strconv.FormatUint(fld.Uint(), base), opts)
+	case reflect.Bool:
+		t, f := c.TrueString, c.FalseString
+		if s, ok := opts.Get("true"); ok {
+			t = s
+		}
+		if s, ok := opts.Get("false"); ok {
+			f = s
+		}
+		if fld.Bool() {
+			return t, nil
+		}
+		return f, nil
+	}
+	return fmt.Sprintf("%v", fld.Interface()), nil
+}
+
+// FormatValue renders a single value, such as one database/sql scanned into
+// an interface{}, with the encoder settings. nil is written as NullToken.
+func (c *CSVEncoder) FormatValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return c.NullToken, nil
+	case []byte:
+		return c.escape(string(v)), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Struct && !isScalarStruct(rv.Type()) {
+		return fmt.Sprintf("%v", v), nil
+	}
+	return c.formatValue(rv, nil)
+}
+
+// escape escapes s when EscapeFormulas is set.
+func (c *CSVEncoder) escape(s string) string {
+	if c.EscapeFormulas {
+		return shared.EscapeFormula(s)
+	}
+	return s
+}
+
+// localize applies the field's number locale to a formatted number and
+// appends a percent sign for fields with the percent tag option.
+func (c *CSVEncoder) localize(s string, opts tagOptions) (string, error) {
+	loc, err := numberLocale(c.Locale, opts)
+	if err != nil {
+		return "", err
+	}
+	if !opts.Has("percent") {
+		return localizeNumber(s, loc), nil
+	}
+	if loc != nil && loc.Currency != "" {
+		l := *loc
+		l.Currency = ""
+		loc = &l
+	}
+	return localizeNumber(s, loc) + "%", nil
+}
+
+// validDelim reports whether r can separate fields, by the rules of
+// encoding/csv.
+func validDelim(r rune) bool {
+	return r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
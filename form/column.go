@@ -0,0 +1,168 @@
+package form
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// columnSpec describes where a field's value lives in a row. The column part
+// of a relation map entry is one of
+//
+//	"Email"                     a single column
+//	"{first_name} {last_name}"  a template combining several columns
+//	"address[0]"                part 0 of the comma separated address column
+//	"name[1: ]"                 part 1 of the space separated name column
+//
+// Templates join their columns into one field on decode and split the field
+// back into its columns on encode. Part selectors let several fields share a
+// column: each field reads its own part on decode, and the parts are joined
+// with the separator on encode.
+type columnSpec struct {
+	columns  []string
+	literals []string
+	part     int
+	sep      string
+}
+
+func parseColumnSpec(s string) (*columnSpec, error) {
+	if strings.Contains(s, "{") {
+		return parseTemplate(s)
+	}
+
+	spec := &columnSpec{columns: []string{s}, part: -1}
+	open := strings.LastIndex(s, "[")
+	if open < 1 || !strings.HasSuffix(s, "]") {
+		return spec, nil
+	}
+
+	sel := s[open+1 : len(s)-1]
+	sep := ","
+	if i := strings.Index(sel, ":"); i >= 0 {
+		sel, sep = sel[:i], sel[i+1:]
+	}
+	n, err := strconv.Atoi(sel)
+	if err != nil || n < 0 || sep == "" {
+		return nil, fmt.Errorf("csv/form: invalid column selector %q", s)
+	}
+	spec.columns[0], spec.part, spec.sep = s[:open], n, sep
+	return spec, nil
+}
+
+func parseTemplate(s string) (*columnSpec, error) {
+	spec := &columnSpec{part: -1}
+	rest := s
+	for {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			spec.literals = append(spec.literals, rest)
+			break
+		}
+		end := strings.Index(rest[open:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("csv/form: unterminated column in template %q", s)
+		}
+		name := rest[open+1 : open+end]
+		if name == "" {
+			return nil, fmt.Errorf("csv/form: empty column in template %q", s)
+		}
+		spec.literals = append(spec.literals, rest[:open])
+		spec.columns = append(spec.columns, name)
+		rest = rest[open+end+1:]
+	}
+	return spec, nil
+}
+
+func (s *columnSpec) isTemplate() bool {
+	return s.literals != nil
+}
+
+// read builds the field value from a row. It reports false when none of the
+// columns are present in the header.
+func (s *columnSpec) read(header map[string]int, cell func(int) string) (string, bool) {
+	if !s.isTemplate() {
+		i, ok := header[s.columns[0]]
+		if !ok {
+			return "", false
+		}
+		v := cell(i)
+		if s.part < 0 {
+			return v, true
+		}
+		parts := strings.Split(v, s.sep)
+		if s.part >= len(parts) {
+			return "", true
+		}
+		return strings.TrimSpace(parts[s.part]), true
+	}
+
+	found, filled := false, false
+	vals := make([]string, len(s.columns))
+	for n, col := range s.columns {
+		i, ok := header[col]
+		if !ok {
+			continue
+		}
+		found = true
+		vals[n] = cell(i)
+		filled = filled || vals[n] != ""
+	}
+	if !filled {
+		return "", found
+	}
+
+	v := s.literals[0]
+	for n := range vals {
+		v += vals[n] + s.literals[n+1]
+	}
+	return v, true
+}
+
+// split breaks a field value back into one value per template column by
+// matching the literal text between the placeholders.
+func (s *columnSpec) split(v string) []string {
+	vals := make([]string, len(s.columns))
+	rest := strings.TrimPrefix(v, s.literals[0])
+	for n := range s.columns {
+		if n == len(s.columns)-1 {
+			vals[n] = strings.TrimSuffix(rest, s.literals[n+1])
+			break
+		}
+		lit := s.literals[n+1]
+		i := -1
+		if lit != "" {
+			i = strings.Index(rest, lit)
+		}
+		if i < 0 {
+			vals[n] = rest
+			break
+		}
+		vals[n], rest = rest[:i], rest[i+len(lit):]
+	}
+	return vals
+}
+
+// setPart stores v as the selected part of cell.
+func (s *columnSpec) setPart(cell, v string) string {
+	var parts []string
+	if cell != "" {
+		parts = strings.Split(cell, s.sep)
+	}
+	for len(parts) <= s.part {
+		parts = append(parts, "")
+	}
+	parts[s.part] = v
+	return strings.Join(parts, s.sep)
+}
+
+func getColumnSpec(key string, m map[string][]string) (*columnSpec, bool, error) {
+	name, ok := getColumnName(key, m)
+	if !ok {
+		return nil, false, nil
+	}
+	spec, err := parseColumnSpec(name)
+	if err != nil {
+		return nil, false, err
+	}
+	return spec, true, nil
+}
@@ -0,0 +1,133 @@
+package form
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColumnSpec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *columnSpec
+		err  bool
+	}{
+		{"Email", &columnSpec{columns: []string{"Email"}, part: -1}, false},
+		{"address[0]", &columnSpec{columns: []string{"address"}, part: 0, sep: ","}, false},
+		{"name[1: ]", &columnSpec{columns: []string{"name"}, part: 1, sep: " "}, false},
+		{"[0]", &columnSpec{columns: []string{"[0]"}, part: -1}, false},
+		{"{first} {last}", &columnSpec{columns: []string{"first", "last"}, literals: []string{"", " ", ""}, part: -1}, false},
+		{"<{a}>", &columnSpec{columns: []string{"a"}, literals: []string{"<", ">"}, part: -1}, false},
+		{"name[x]", nil, true},
+		{"name[-1]", nil, true},
+		{"name[1:]", nil, true},
+		{"{first", nil, true},
+		{"{}", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseColumnSpec(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: err = %v", tt.in, err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestColumnSpecSplit(t *testing.T) {
+	tests := []struct {
+		spec string
+		in   string
+		want []string
+	}{
+		{"{first} {last}", "John Doe", []string{"John", "Doe"}},
+		{"{first} {last}", "John", []string{"John", ""}},
+		{"{first} {last}", "Mary Ann Doe", []string{"Mary", "Ann Doe"}},
+		{"<{a}|{b}>", "<1|2>", []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		spec, err := parseColumnSpec(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := spec.split(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q.split(%q) = %q, want %q", tt.spec, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestColumnSpecSetPart(t *testing.T) {
+	spec, _ := parseColumnSpec("addr[2]")
+	if got := spec.setPart("", "x"); got != ",,x" {
+		t.Errorf("setPart on empty = %q", got)
+	}
+	if got := spec.setPart("a,b,c,d", "x"); got != "a,b,x,d" {
+		t.Errorf("setPart = %q", got)
+	}
+}
+
+func TestTemplatesAndParts(t *testing.T) {
+	type person struct {
+		Name   string `csvform:"name"`
+		Street string `csvform:"street"`
+		City   string `csvform:"city"`
+	}
+	rel := map[string][]string{
+		"name":   {"{first_name} {last_name}"},
+		"street": {"address[0]"},
+		"city":   {"address[1]"},
+	}
+	in := []byte("first_name,last_name,address\nJohn,Doe,\"1 Main St, Springfield\"\nAnn,,\"2 Elm St\"")
+
+	var got []person
+	if err := Unmarshal(in, &got, rel); err != nil {
+		t.Fatal(err)
+	}
+	want := []person{
+		{"John Doe", "1 Main St", "Springfield"},
+		{"Ann ", "2 Elm St", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unmarshal = %q, want %q", got, want)
+	}
+
+	b, err := Marshal(want[:1], rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back []person
+	if err := Unmarshal(b, &back, rel); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, want[:1]) {
+		t.Errorf("round trip = %q, want %q\n%s", back, want[:1], b)
+	}
+}
+
+func TestDecoderCachesSpecs(t *testing.T) {
+	type row struct {
+		Name  string `csvform:"name"`
+		First string `csvform:"first"`
+		Note  string `csvform:"note"`
+	}
+	rel := map[string][]string{"name": {"{first} {last}"}, "first": {"name[0: ]"}}
+	dec, err := NewCSVRelationDecoder([]byte("first,last,name\nAda,Lovelace,Ada King\nAlan,Turing,Alan Turing"), rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []row
+	if err := dec.Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := []row{{"Ada Lovelace", "Ada", ""}, {"Alan Turing", "Alan", ""}}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("Decode = %v, want %v", out, want)
+	}
+	name, _ := parseColumnSpec("{first} {last}")
+	first, _ := parseColumnSpec("name[0: ]")
+	wantSpecs := map[string]*columnSpec{"name": name, "first": first, "note": nil}
+	if !reflect.DeepEqual(dec.specs, wantSpecs) {
+		t.Errorf("specs = %+v, want %+v", dec.specs, wantSpecs)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"io"
 	"reflect"
 	"strconv"
+
+	"github.com/xiphoid24/csv/internal/shared"
 )
 
 type InvalidUnmarshalError struct {
@@ -31,7 +33,21 @@ func (e *InvalidUnmarshalError) Error() string {
 	return "csv: Unmarshal(nil " + e.Type.String() + ")"
 }
 
-var EMPTYROW = errors.New("CSV EMPTY ROW")
+var EMPTYROW = shared.EmptyRow
+
+// RowError reports a problem with a single data row. Row is the index of the
+// record in the input, with the header at row 0.
+type RowError = shared.RowError
+
+// RangeError reports a number that does not fit its field, either because
+// it overflows the field's type or because it is outside the field's csvmin
+// or csvmax tag. Column is the field's relation map key.
+type RangeError = shared.RangeError
+
+// Validator is implemented by types that check their own business rules.
+// Decode calls Validate on every decoded element and reports a failure as a
+// *RowError.
+type Validator = shared.Validator
 
 type CSVRelationDecoder struct {
 	Rdr         *csv.Reader
@@ -39,15 +55,38 @@ type CSVRelationDecoder struct {
 	HeaderMap   map[string]int
 	RelationMap map[string][]string
 	RowFilled   bool
+	EmptyRows   EmptyRowPolicy
+	Validate    func(row int, v interface{}) error
+
+	// Limits bounds the rows, columns and bytes NewCSVRelationDecoder
+	// reads.
+	Limits Limits
+
+	// lines holds the input line each of Rows starts on.
+	lines []int
+	// specs caches the parsed column of each relation key, nil for keys
+	// that are not mapped, so it is parsed once rather than on every row.
+	specs map[string]*columnSpec
 }
 
-func NewCSVRelationDecoder(b []byte, rel map[string][]string) (*CSVRelationDecoder, error) {
+func NewCSVRelationDecoder(b []byte, rel map[string][]string, opts ...DecoderOption) (*CSVRelationDecoder, error) {
+	return NewCSVRelationDecoderFrom(bytes.NewReader(b), rel, opts...)
+}
+
+// NewCSVRelationDecoderFrom is like NewCSVRelationDecoder but reads the
+// records from r. It stops reading as soon as the input goes over the
+// decoder's Limits, so r can be an upload that is never held in memory
+// whole.
+func NewCSVRelationDecoderFrom(r io.Reader, rel map[string][]string, opts ...DecoderOption) (*CSVRelationDecoder, error) {
 	if rel == nil {
 		return nil, fmt.Errorf("csv: nil relationship map")
 	}
 
 	c := new(CSVRelationDecoder)
-	c.Rdr = csv.NewReader(bytes.NewBuffer(b))
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.Rdr = csv.NewReader(shared.LimitReader(r, c.Limits))
 	for {
 		row, err := c.Rdr.Read()
 		if err == io.EOF {
@@ -56,7 +95,12 @@ func NewCSVRelationDecoder(b []byte, rel map[string][]string) (*CSVRelationDecod
 		if err != nil {
 			return nil, err
 		}
+		if err := shared.CheckRow(c.Limits, len(c.Rows), row); err != nil {
+			return nil, err
+		}
+		line, _ := c.Rdr.FieldPos(0)
 		c.Rows = append(c.Rows, row)
+		c.lines = append(c.lines, line)
 	}
 	if len(c.Rows) < 1 {
 		return nil, fmt.Errorf("csv: error reading rows")
@@ -69,10 +113,17 @@ func NewCSVRelationDecoder(b []byte, rel map[string][]string) (*CSVRelationDecod
 	}
 
 	c.RelationMap = rel
+	c.specs = map[string]*columnSpec{}
 	return c, nil
 }
 
-func Unmarshal(b []byte, v interface{}, rel map[string][]string) error {
+func Unmarshal(b []byte, v interface{}, rel map[string][]string, opts ...DecoderOption) error {
+	return UnmarshalReader(bytes.NewReader(b), v, rel, opts...)
+}
+
+// UnmarshalReader is like Unmarshal but reads the CSV data from r,
+// stopping early when it goes over the Limits set with WithLimits.
+func UnmarshalReader(r io.Reader, v interface{}, rel map[string][]string, opts ...DecoderOption) error {
 
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
@@ -88,7 +139,7 @@ func Unmarshal(b []byte, v interface{}, rel map[string][]string) error {
 		return &InvalidUnmarshalError{reflect.TypeOf(v)}
 	}
 
-	decoder, err := NewCSVRelationDecoder(b, rel)
+	decoder, err := NewCSVRelationDecoderFrom(r, rel, opts...)
 	if err != nil {
 		return err
 	}
@@ -99,7 +150,7 @@ func Unmarshal(b []byte, v interface{}, rel map[string][]string) error {
 	return nil
 }
 
-func UnmarshalRow(row int, b []byte, v interface{}, rel map[string][]string) error {
+func UnmarshalRow(row int, b []byte, v interface{}, rel map[string][]string, opts ...DecoderOption) error {
 
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
@@ -111,27 +162,30 @@ func UnmarshalRow(row int, b []byte, v interface{}, rel map[string][]string) err
 		return &InvalidUnmarshalError{reflect.TypeOf(v)}
 	}
 
-	decoder, err := NewCSVRelationDecoder(b, rel)
+	decoder, err := NewCSVRelationDecoder(b, rel, opts...)
 	if err != nil {
 		return err
 	}
 
-	if row > len(decoder.Rows) || row < 1 {
+	if row >= len(decoder.Rows) || row < 1 {
 		return fmt.Errorf("csv: Invalid row")
 	}
-
-	if err := decoder.DecodeRelationRow(row, rv.Elem(), ""); err != nil {
+	if err := checkRecursive(rv.Elem().Type()); err != nil {
 		return err
 	}
 
+	if err := decoder.decodeInto(row, rv.Elem()); err != nil {
+		return shared.AtLine(&RowError{Row: row, Err: err}, decoder.lines)
+	}
+
 	return nil
 }
 
 func (c *CSVRelationDecoder) GetFieldInRow(r, f int) string {
-	if len(c.Rows) < r {
+	if len(c.Rows) <= r {
 		return ""
 	}
-	if len(c.Rows[r]) < f {
+	if len(c.Rows[r]) <= f {
 		return ""
 	}
 	return c.Rows[r][f]
@@ -147,21 +201,43 @@ func (c *CSVRelationDecoder) Decode(ptr interface{}) error {
 
 	// get type of single element
 	strctTyp := val.Type().Elem()
+	if err := checkRecursive(strctTyp); err != nil {
+		return err
+	}
 
 	for rowNum := 1; rowNum < len(c.Rows); rowNum++ {
 		c.RowFilled = false
 		strct := reflect.Indirect(reflect.New(strctTyp))
-		if err := c.DecodeRelationRow(rowNum, strct, ""); err != nil {
-			return err
+		if err := c.decodeInto(rowNum, strct); err != nil {
+			return shared.AtLine(&RowError{Row: rowNum, Err: err}, c.lines)
 		}
 
-		if c.RowFilled {
-			val.Set(reflect.Append(val, strct))
+		if !c.RowFilled {
+			switch c.EmptyRows {
+			case SkipEmptyRows:
+				continue
+			case ErrorEmptyRows:
+				return shared.AtLine(&RowError{Row: rowNum, Err: EMPTYROW}, c.lines)
+			}
+		}
+		if err := c.validate(rowNum, strct); err != nil {
+			return shared.AtLine(err, c.lines)
 		}
+		val.Set(reflect.Append(val, strct))
 	}
 	return nil
 }
 
+// validate runs the element's own Validate method, if it has one, followed
+// by the decoder's Validate hook.
+func (c *CSVRelationDecoder) validate(rowNum int, strct reflect.Value) error {
+	return shared.Validate(rowNum, strct.Addr().Interface(), c.Validate)
+}
+
+// DecodeRelationRow fills strct from row rowNum, reading each field tagged
+// csvform from the columns the relation map gives its key. Numeric fields
+// can carry csvmin and csvmax tags, e.g. `csvform:"qty" csvmin:"1"`, that
+// bound their value like the csv package's min and max tag options.
 func (c *CSVRelationDecoder) DecodeRelationRow(rowNum int, strct reflect.Value, start string) error {
 	strctTyp := strct.Type()
 
@@ -195,36 +271,48 @@ func (c *CSVRelationDecoder) DecodeRelationRow(rowNum int, strct reflect.Value,
 			fld.Set(st)
 			continue
 		}
-		columnName, ok := getColumnName(start+formtag, c.RelationMap)
-		if !ok {
+
+		if isStructPtr(fld.Type()) {
+			if err := c.decodePtr(rowNum, fld, start+formtag); err != nil {
+				return err
+			}
 			continue
 		}
-		columnNum, ok := c.HeaderMap[columnName]
-		if !ok {
-			continue
+
+		csvVal, err := c.value(rowNum, start+formtag)
+		if err != nil {
+			return err
 		}
-		csvVal := c.GetFieldInRow(rowNum, columnNum)
 		if csvVal == "" {
 			continue
 		}
-		c.RowFilled = true
+		key := start + formtag
 		switch fld.Kind() {
 		case reflect.String:
 			fld.SetString(csvVal)
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			in, err := strconv.ParseInt(csvVal, 10, 64)
+			in, err := strconv.ParseInt(csvVal, 10, fld.Type().Bits())
+			if shared.IsRangeErr(err) {
+				return &RangeError{Column: key, Value: csvVal, Type: fld.Type()}
+			}
 			if err != nil {
 				return fmt.Errorf("csv: %s +  Must be a a number", name)
 			}
 			fld.SetInt(in)
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			u, err := strconv.ParseUint(csvVal, 10, 64)
+			u, err := strconv.ParseUint(csvVal, 10, fld.Type().Bits())
+			if shared.IsRangeErr(err) {
+				return &RangeError{Column: key, Value: csvVal, Type: fld.Type()}
+			}
 			if err != nil {
 				return fmt.Errorf("csv: %s +  Must be a a number", name)
 			}
 			fld.SetUint(u)
 		case reflect.Float32, reflect.Float64:
-			f, err := strconv.ParseFloat(csvVal, 64)
+			f, err := strconv.ParseFloat(csvVal, fld.Type().Bits())
+			if shared.IsRangeErr(err) {
+				return &RangeError{Column: key, Value: csvVal, Type: fld.Type()}
+			}
 			if err != nil {
 				return fmt.Errorf("csv: %s +  Must be a a number", name)
 			}
@@ -236,7 +324,72 @@ func (c *CSVRelationDecoder) DecodeRelationRow(rowNum int, strct reflect.Value,
 			}
 			fld.SetBool(b)
 		}
+		field := strctTyp.Field(fieldNum)
+		if err := shared.CheckBounds(fld, csvVal, field.Tag.Get("csvmin"), field.Tag.Get("csvmax")); err != nil {
+			if re, ok := err.(*RangeError); ok {
+				re.Column = key
+			}
+			return err
+		}
 	}
 
 	return nil
 }
+
+// value returns the transformed cell mapped to key in a row, or "" when key
+// is not in the relation map. A non-empty value marks the row as filled.
+func (c *CSVRelationDecoder) value(rowNum int, key string) (string, error) {
+	spec, err := c.columnSpec(key)
+	if err != nil || spec == nil {
+		return "", err
+	}
+	csvVal, ok := spec.read(c.HeaderMap, func(i int) string {
+		return c.GetFieldInRow(rowNum, i)
+	})
+	if !ok {
+		return "", nil
+	}
+	csvVal, err = applyTransforms(csvVal, getTransforms(key, c.RelationMap), decodeDir)
+	if err != nil {
+		return "", err
+	}
+	if csvVal != "" {
+		c.RowFilled = true
+	}
+	return csvVal, nil
+}
+
+// columnSpec returns the parsed column mapped to key, or nil when key is
+// not in the relation map.
+func (c *CSVRelationDecoder) columnSpec(key string) (*columnSpec, error) {
+	if spec, ok := c.specs[key]; ok {
+		return spec, nil
+	}
+	spec, _, err := getColumnSpec(key, c.RelationMap)
+	if err != nil {
+		return nil, err
+	}
+	c.specs[key] = spec
+	return spec, nil
+}
+
+// decodePtr decodes a pointer-to-struct field. A nil pointer is only
+// allocated when at least one of its mapped columns has a value, so optional
+// sub-objects stay nil for rows that leave them blank.
+func (c *CSVRelationDecoder) decodePtr(rowNum int, fld reflect.Value, start string) error {
+	if !fld.IsNil() {
+		return c.DecodeRelationRow(rowNum, fld.Elem(), start)
+	}
+
+	filled := c.RowFilled
+	c.RowFilled = false
+	st := reflect.New(fld.Type().Elem())
+	if err := c.DecodeRelationRow(rowNum, st.Elem(), start); err != nil {
+		return err
+	}
+	if c.RowFilled {
+		fld.Set(st)
+	}
+	c.RowFilled = c.RowFilled || filled
+	return nil
+}
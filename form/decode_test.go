@@ -0,0 +1,178 @@
+package form
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEmptyRows(t *testing.T) {
+	type row struct {
+		Name string `csvform:"name"`
+		Qty  int    `csvform:"qty"`
+	}
+	rel := map[string][]string{"name": {"Name"}, "qty": {"Qty"}}
+	in := []byte("Name,Qty\na,1\n\n,\nb,2")
+	tests := []struct {
+		policy EmptyRowPolicy
+		want   []row
+		line   int
+	}{
+		{SkipEmptyRows, []row{{"a", 1}, {"b", 2}}, 0},
+		{KeepEmptyRows, []row{{"a", 1}, {}, {"b", 2}}, 0},
+		{ErrorEmptyRows, nil, 4},
+	}
+	for _, tt := range tests {
+		var got []row
+		err := Unmarshal(in, &got, rel, WithEmptyRows(tt.policy))
+		if tt.line > 0 {
+			var re *RowError
+			if !errors.As(err, &re) || !errors.Is(err, EMPTYROW) || re.Row != 2 || re.Line != tt.line {
+				t.Errorf("policy %d: got %v, want EMPTYROW at row 2, line %d", tt.policy, err, tt.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("policy %d: %v", tt.policy, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("policy %d: got %v, want %v", tt.policy, got, tt.want)
+		}
+	}
+}
+
+type checked struct {
+	Name string `csvform:"name"`
+	Qty  int    `csvform:"qty"`
+}
+
+func (c *checked) Validate() error {
+	if c.Qty < 0 {
+		return errors.New("negative qty")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	rel := map[string][]string{"name": {"Name"}, "qty": {"Qty"}}
+	noB := func(row int, v interface{}) error {
+		if v.(*checked).Name == "b" {
+			return errors.New("no b")
+		}
+		return nil
+	}
+	tests := []struct {
+		in   string
+		hook func(int, interface{}) error
+		row  int
+		err  string
+	}{
+		{"Name,Qty\na,1\nb,2", nil, 0, ""},
+		{"Name,Qty\na,1\nb,-2", nil, 2, "negative qty"},
+		{"Name,Qty\na,1\nb,2", noB, 2, "no b"},
+		// the element's own method runs before the hook
+		{"Name,Qty\nb,-1", noB, 1, "negative qty"},
+	}
+	for _, tt := range tests {
+		var got []checked
+		err := Unmarshal([]byte(tt.in), &got, rel, WithValidator(tt.hook))
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%q: %v", tt.in, err)
+			}
+			continue
+		}
+		var re *RowError
+		if !errors.As(err, &re) || re.Row != tt.row || re.Err.Error() != tt.err {
+			t.Errorf("%q: got %v, want %q at row %d", tt.in, err, tt.err, tt.row)
+		}
+	}
+}
+
+func TestRangeErrors(t *testing.T) {
+	type row struct {
+		I8  int8    `csvform:"i8"`
+		U8  uint8   `csvform:"u8"`
+		F32 float32 `csvform:"f32"`
+		Qty int     `csvform:"qty" csvmin:"1" csvmax:"10"`
+		Pct float64 `csvform:"pct" csvmin:"0" csvmax:"1"`
+	}
+	rel := map[string][]string{"i8": {"i8"}, "u8": {"u8"}, "f32": {"f32"}, "qty": {"qty"}, "pct": {"pct"}}
+	tests := []struct {
+		in       string
+		col      string
+		min, max string
+	}{
+		{"1,1,1,5,0.5", "", "", ""},
+		{"300,1,1,5,0.5", "i8", "", ""},
+		{"1,256,1,5,0.5", "u8", "", ""},
+		{"1,1,1e39,5,0.5", "f32", "", ""},
+		{"1,1,1,0,0.5", "qty", "1", ""},
+		{"1,1,1,11,0.5", "qty", "", "10"},
+		{"1,1,1,5,NaN", "pct", "0", ""},
+	}
+	for _, tt := range tests {
+		var out []row
+		err := Unmarshal([]byte("i8,u8,f32,qty,pct\n"+tt.in), &out, rel)
+		if tt.col == "" {
+			if err != nil {
+				t.Errorf("%q: %v", tt.in, err)
+			}
+			continue
+		}
+		var re *RangeError
+		if !errors.As(err, &re) || re.Column != tt.col || re.Min != tt.min || re.Max != tt.max {
+			t.Errorf("%q: got %v, want a range error on %s", tt.in, err, tt.col)
+		}
+	}
+}
+
+func TestConversionRowError(t *testing.T) {
+	type row struct {
+		Name string `csvform:"name"`
+		Qty  int    `csvform:"qty"`
+	}
+	rel := map[string][]string{"name": {"name"}, "qty": {"qty"}}
+	in := []byte("name,qty\na,1\n\nb,x\nc,3")
+	want := "csv: row 2 (line 4): csv: Qty +  Must be a a number"
+
+	var out []row
+	errs := map[string]error{
+		"Unmarshal":    Unmarshal(in, &out, rel),
+		"UnmarshalRow": UnmarshalRow(2, in, &row{}, rel),
+	}
+	for name, err := range errs {
+		var re *RowError
+		if !errors.As(err, &re) || re.Row != 2 || re.Line != 4 || err.Error() != want {
+			t.Errorf("%s: error = %v, want %s", name, err, want)
+		}
+	}
+}
+
+func TestRowBounds(t *testing.T) {
+	type row struct {
+		Name string `csvform:"name"`
+	}
+	rel := map[string][]string{"name": {"name"}}
+	in := []byte("name\na\nb")
+	for _, n := range []int{0, 3, 4} {
+		if err := UnmarshalRow(n, in, &row{}, rel); err == nil {
+			t.Errorf("UnmarshalRow(%d): expected an error", n)
+		}
+	}
+	var got row
+	if err := UnmarshalRow(2, in, &got, rel); err != nil || got.Name != "b" {
+		t.Errorf("UnmarshalRow(2) = %v, %v, want b", got, err)
+	}
+
+	dec, err := NewCSVRelationDecoder(in, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rc := range [][2]int{{3, 0}, {1, 1}} {
+		if v := dec.GetFieldInRow(rc[0], rc[1]); v != "" {
+			t.Errorf("GetFieldInRow(%d, %d) = %q, want empty", rc[0], rc[1], v)
+		}
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
-	"strings"
+
+	"github.com/xiphoid24/csv/internal/shared"
 )
 
 type CSVRelationEncoder struct {
@@ -14,9 +15,20 @@ type CSVRelationEncoder struct {
 	RowCache    []string
 	count       int
 	added       bool
+	columns     map[string]int
+	specs       map[string]*columnSpec
+
+	// Columns lists header columns, by column name or relation map key,
+	// that are written first and in the given order.
+	Columns []string
+	order   []int
 }
 
-func Marshal(v interface{}, rel map[string][]string) ([]byte, error) {
+// Marshal encodes v, a struct or a slice of structs, with a header row built
+// from rel. Elements whose cells are all empty are left out, so they do not
+// read back, and neither do pointers to structs whose cells are all empty,
+// which Unmarshal leaves nil. "\r\n" inside a value reads back as "\n".
+func Marshal(v interface{}, rel map[string][]string, opts ...EncoderOption) ([]byte, error) {
 	val := reflect.ValueOf(v)
 
 	if val.Kind() == reflect.Slice {
@@ -27,7 +39,7 @@ func Marshal(v interface{}, rel map[string][]string) ([]byte, error) {
 		return nil, fmt.Errorf("csv error: expected a struct or a list of struct\n")
 	}
 
-	encoder, err := NewCSVRelationEncoder(val, rel)
+	encoder, err := NewCSVRelationEncoder(val, rel, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +60,7 @@ func (c *CSVRelationEncoder) String() string {
 	return s
 }
 
-func NewCSVRelationEncoder(v reflect.Value, rel map[string][]string) (*CSVRelationEncoder, error) {
+func NewCSVRelationEncoder(v reflect.Value, rel map[string][]string, opts ...EncoderOption) (*CSVRelationEncoder, error) {
 	if rel == nil {
 		return nil, fmt.Errorf("csv/form: nil relationship map")
 	}
@@ -57,6 +69,11 @@ func NewCSVRelationEncoder(v reflect.Value, rel map[string][]string) (*CSVRelati
 		RelationMap: rel,
 		Rows:        [][]byte{},
 		count:       0,
+		columns:     map[string]int{},
+		specs:       map[string]*columnSpec{},
+	}
+	for _, opt := range opts {
+		opt(exporter)
 	}
 
 	if err := exporter.EncodeHeader(v); err != nil {
@@ -72,6 +89,9 @@ func (c *CSVRelationEncoder) EncodeHeader(v reflect.Value) error {
 	if v.Kind() != reflect.Struct {
 		return fmt.Errorf("csv error: expected a struct or a list of struct\n")
 	}
+	if err := checkRecursive(v.Type()); err != nil {
+		return err
+	}
 	c.RowCache = []string{}
 	/*i := 0
 	for k, _ := range c.RelationMap {
@@ -88,7 +108,12 @@ func (c *CSVRelationEncoder) EncodeHeader(v reflect.Value) error {
 	if len(c.RowCache) < 1 {
 		return fmt.Errorf("csv/form: Empty relationship map")
 	}
-	c.Rows = append(c.Rows, []byte(strings.Join(c.RowCache, ",")))
+	order, err := c.columnOrder()
+	if err != nil {
+		return err
+	}
+	c.order = order
+	c.Rows = append(c.Rows, shared.JoinRow(c.permute(c.RowCache), 0))
 	return nil
 }
 
@@ -122,12 +147,31 @@ func (c *CSVRelationEncoder) encodeHeader(strctVal reflect.Value, start string)
 			if err := c.encodeHeader(reflect.Indirect(fld), start+formtag); err != nil {
 				return err
 			}
+		case reflect.Ptr:
+			if !isStructPtr(fld.Type()) {
+				continue
+			}
+			if err := c.encodeHeader(reflect.New(fld.Type().Elem()).Elem(), start+formtag); err != nil {
+				return err
+			}
 		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.Bool:
-			if header, ok := getColumnName(start+formtag, c.RelationMap); ok {
+			spec, ok, err := getColumnSpec(start+formtag, c.RelationMap)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			for _, header := range spec.columns {
+				if _, ok := c.columns[header]; ok {
+					continue
+				}
 				c.RowCache = append(c.RowCache, header)
-				c.HeaderMap[start+formtag] = c.count
+				c.columns[header] = c.count
 				c.count++
 			}
+			c.HeaderMap[start+formtag] = c.columns[spec.columns[0]]
+			c.specs[start+formtag] = spec
 		}
 	}
 
@@ -137,21 +181,23 @@ func (c *CSVRelationEncoder) encodeHeader(strctVal reflect.Value, start string)
 
 func (c *CSVRelationEncoder) Encode(v reflect.Value) ([]byte, error) {
 	if v.Kind() == reflect.Struct {
-		if err := c.EncodeRelationRow(v, ""); err != nil {
+		c.RowCache = make([]string, c.count)
+		if err := c.encodeCells(v); err != nil {
 			return nil, err
 		}
+		c.Rows = append(c.Rows, shared.JoinRow(c.permute(c.RowCache), 0))
 		return bytes.Join(c.Rows, []byte("\n")), nil
 	}
 
 	for i := 0; i < v.Len(); i++ {
 		c.added = false
 		strctVal := v.Index(i)
-		c.RowCache = make([]string, len(c.HeaderMap))
-		if err := c.EncodeRelationRow(strctVal, ""); err != nil {
+		c.RowCache = make([]string, c.count)
+		if err := c.encodeCells(strctVal); err != nil {
 			return nil, err
 		}
 		if c.added {
-			c.Rows = append(c.Rows, []byte(strings.Join(c.RowCache, ",")))
+			c.Rows = append(c.Rows, shared.JoinRow(c.permute(c.RowCache), 0))
 		}
 	}
 	return bytes.Join(c.Rows, []byte("\n")), nil
@@ -186,14 +232,94 @@ func (c *CSVRelationEncoder) EncodeRelationRow(strctVal reflect.Value, start str
 			if err := c.EncodeRelationRow(reflect.Indirect(fld), start+formtag); err != nil {
 				return err
 			}
+		case reflect.Ptr:
+			// nil sub-objects leave their cells empty
+			if !isStructPtr(fld.Type()) || fld.IsNil() {
+				continue
+			}
+			if err := c.EncodeRelationRow(fld.Elem(), start+formtag); err != nil {
+				return err
+			}
 		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.Bool:
-			if i, ok := c.HeaderMap[start+formtag]; ok {
-				if s := fmt.Sprintf("%v", fld.Interface()); s != "" {
-					c.RowCache[i] = s
-					c.added = true
-				}
+			if err := c.setValue(start+formtag, fmt.Sprintf("%v", fld.Interface())); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
+
+// columnOrder puts the columns named in c.Columns first, in that order,
+// followed by the rest in struct field order.
+func (c *CSVRelationEncoder) columnOrder() ([]int, error) {
+	if len(c.Columns) == 0 {
+		return nil, nil
+	}
+	used := make([]bool, c.count)
+	order := make([]int, 0, c.count)
+	for _, col := range c.Columns {
+		i, ok := c.columns[col]
+		if !ok {
+			i, ok = c.HeaderMap[col]
+		}
+		if !ok {
+			return nil, fmt.Errorf("csv/form: unknown column %q", col)
+		}
+		if !used[i] {
+			used[i] = true
+			order = append(order, i)
+		}
+	}
+	for i := 0; i < c.count; i++ {
+		if !used[i] {
+			order = append(order, i)
+		}
+	}
+	return order, nil
+}
+
+// permute rearranges a row built in struct field order into output order.
+func (c *CSVRelationEncoder) permute(cells []string) []string {
+	if c.order == nil {
+		return cells
+	}
+	out := make([]string, len(c.order))
+	for i, j := range c.order {
+		out[i] = cells[j]
+	}
+	return out
+}
+
+// setValue transforms a formatted field value and writes it into the
+// cells mapped to key. Keys without a column are ignored.
+func (c *CSVRelationEncoder) setValue(key, s string) error {
+	spec, ok := c.specs[key]
+	if !ok {
+		return nil
+	}
+	s, err := applyTransforms(s, getTransforms(key, c.RelationMap), encodeDir)
+	if err != nil {
+		return err
+	}
+	if s != "" {
+		c.setCells(spec, s)
+		c.added = true
+	}
+	return nil
+}
+
+// setCells writes a formatted field value into the row cells its column
+// spec points at.
+func (c *CSVRelationEncoder) setCells(spec *columnSpec, s string) {
+	switch {
+	case spec.isTemplate():
+		for n, v := range spec.split(s) {
+			c.RowCache[c.columns[spec.columns[n]]] = v
+		}
+	case spec.part >= 0:
+		i := c.columns[spec.columns[0]]
+		c.RowCache[i] = spec.setPart(c.RowCache[i], s)
+	default:
+		c.RowCache[c.columns[spec.columns[0]]] = s
+	}
+}
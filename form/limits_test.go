@@ -0,0 +1,43 @@
+package form
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	type row struct {
+		A string `csvform:"a"`
+		B string `csvform:"b"`
+	}
+	rel := map[string][]string{"a": {"a"}, "b": {"b"}}
+	in := "a,b\n1,2\n3,4\n5,6789"
+	tests := []struct {
+		limits Limits
+		limit  string
+		row    int
+	}{
+		{Limits{MaxRows: 3}, "MaxRows", 3},
+		{Limits{MaxColumns: 1}, "MaxColumns", 0},
+		{Limits{MaxFieldBytes: 3}, "MaxFieldBytes", 3},
+		{Limits{MaxBytes: 10}, "MaxBytes", 0},
+		{Limits{MaxRows: 4, MaxColumns: 2, MaxFieldBytes: 4, MaxBytes: int64(len(in))}, "", 0},
+	}
+	for _, tt := range tests {
+		var out []row
+		err := UnmarshalReader(strings.NewReader(in), &out, rel, WithLimits(tt.limits))
+		if tt.limit == "" {
+			want := []row{{"1", "2"}, {"3", "4"}, {"5", "6789"}}
+			if err != nil || !reflect.DeepEqual(out, want) {
+				t.Errorf("%+v: got %v, %v, want %v", tt.limits, out, err, want)
+			}
+			continue
+		}
+		var le *LimitError
+		if !errors.As(err, &le) || le.Limit != tt.limit || le.Row != tt.row {
+			t.Errorf("%+v: got %v, want %s at row %d", tt.limits, err, tt.limit, tt.row)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package form
+
+import "reflect"
+
+// RowMarshaler is implemented by types that format their own fields,
+// usually through methods generated by cmd/csvgen. MarshalCSVForm calls set
+// with the relation map key and formatted value of each field; the encoder
+// applies transforms and fills the mapped columns.
+type RowMarshaler interface {
+	MarshalCSVForm(set func(key, val string) error) error
+}
+
+// RowUnmarshaler is implemented by types that parse their own fields,
+// usually through methods generated by cmd/csvgen. get returns the
+// transformed value mapped to a relation map key, or "" when there is none.
+type RowUnmarshaler interface {
+	UnmarshalCSVForm(get func(key string) (string, error)) error
+}
+
+// decodeInto decodes a row into strct, through its UnmarshalCSVForm method
+// when it has one.
+func (c *CSVRelationDecoder) decodeInto(rowNum int, strct reflect.Value) error {
+	if u, ok := strct.Addr().Interface().(RowUnmarshaler); ok {
+		return u.UnmarshalCSVForm(func(key string) (string, error) {
+			return c.value(rowNum, key)
+		})
+	}
+	return c.DecodeRelationRow(rowNum, strct, "")
+}
+
+// encodeCells writes strctVal into RowCache, through its MarshalCSVForm
+// method when it has one.
+func (c *CSVRelationEncoder) encodeCells(strctVal reflect.Value) error {
+	v := strctVal.Interface()
+	if strctVal.CanAddr() {
+		v = strctVal.Addr().Interface()
+	}
+	if m, ok := v.(RowMarshaler); ok {
+		return m.MarshalCSVForm(c.setValue)
+	}
+	return c.EncodeRelationRow(strctVal, "")
+}
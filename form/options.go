@@ -0,0 +1,57 @@
+package form
+
+import "github.com/xiphoid24/csv/internal/shared"
+
+// EmptyRowPolicy controls what Decode does with a row that leaves every
+// field empty.
+type EmptyRowPolicy = shared.EmptyRowPolicy
+
+const (
+	// SkipEmptyRows drops empty rows. It is the default.
+	SkipEmptyRows = shared.SkipEmptyRows
+	// KeepEmptyRows appends a zero value for each empty row, so the output
+	// stays aligned with the records of the input. Blank lines are not
+	// records; RowError.Line gives the line of a record.
+	KeepEmptyRows = shared.KeepEmptyRows
+	// ErrorEmptyRows stops decoding with a *RowError wrapping EMPTYROW.
+	ErrorEmptyRows = shared.ErrorEmptyRows
+)
+
+// DecoderOption configures a CSVRelationDecoder before any rows are decoded.
+type DecoderOption func(*CSVRelationDecoder)
+
+// WithEmptyRows sets the policy applied to rows without any values.
+func WithEmptyRows(p EmptyRowPolicy) DecoderOption {
+	return func(c *CSVRelationDecoder) {
+		c.EmptyRows = p
+	}
+}
+
+// WithValidator sets a hook that Decode calls with the row number and a
+// pointer to each decoded element. A non-nil error stops decoding and is
+// returned as a *RowError.
+func WithValidator(fn func(row int, v interface{}) error) DecoderOption {
+	return func(c *CSVRelationDecoder) {
+		c.Validate = fn
+	}
+}
+
+// WithLimits rejects input over l with a *LimitError while it is read.
+func WithLimits(l Limits) DecoderOption {
+	return func(c *CSVRelationDecoder) {
+		c.Limits = l
+	}
+}
+
+// EncoderOption configures a CSVRelationEncoder before the header is
+// written.
+type EncoderOption func(*CSVRelationEncoder)
+
+// OrderColumns writes the named columns first, in the given order. A name
+// can be a column name or a relation map key, so passing the keys of a
+// mapping list keeps the output in the list's order.
+func OrderColumns(cols ...string) EncoderOption {
+	return func(c *CSVRelationEncoder) {
+		c.Columns = cols
+	}
+}
@@ -0,0 +1,43 @@
+package form
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrderColumns(t *testing.T) {
+	type row struct {
+		Name string `csvform:"name"`
+		Qty  int    `csvform:"qty"`
+		Note string `csvform:"note"`
+	}
+	rel := map[string][]string{"name": {"Name"}, "qty": {"Quantity"}, "note": {"Note"}}
+	in := []row{{"a", 1, "n"}}
+	tests := []struct {
+		cols []string
+		want string
+		err  string
+	}{
+		{nil, "Name,Quantity,Note\na,1,n", ""},
+		// keys and column names can be mixed
+		{[]string{"note", "Quantity"}, "Note,Quantity,Name\nn,1,a", ""},
+		{[]string{"qty", "qty"}, "Quantity,Name,Note\n1,a,n", ""},
+		{[]string{"nosuch"}, "", `unknown column "nosuch"`},
+	}
+	for _, tt := range tests {
+		b, err := Marshal(in, rel, OrderColumns(tt.cols...))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got %v, want %s", tt.cols, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.cols, err)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.cols, b, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package form
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	Street string `csvform:"street"`
+	City   string `csvform:"city"`
+}
+
+type customer struct {
+	Name    string   `csvform:"name"`
+	Ship    *address `csvform:"ship"`
+	Billing *address `csvform:"bill"`
+}
+
+var customerRel = map[string][]string{
+	"name":        {"Name"},
+	"ship street": {"Ship Street"},
+	"ship city":   {"Ship City"},
+	"bill street": {"Bill Street"},
+	"bill city":   {"Bill City"},
+}
+
+func TestDecodePointers(t *testing.T) {
+	in := []byte("Name,Ship Street,Ship City,Bill Street,Bill City\n" +
+		"Ann,1 Main St,Springfield,,\n" +
+		"Bob,,,,Shelbyville")
+	var got []customer
+	if err := Unmarshal(in, &got, customerRel); err != nil {
+		t.Fatal(err)
+	}
+	want := []customer{
+		{Name: "Ann", Ship: &address{"1 Main St", "Springfield"}},
+		{Name: "Bob", Billing: &address{City: "Shelbyville"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestEncodePointers(t *testing.T) {
+	in := []customer{
+		{Name: "Ann", Ship: &address{"1 Main St", "Springfield"}},
+		{Name: "Bob"},
+	}
+	b, err := Marshal(in, customerRel, OrderColumns("name", "ship street", "ship city", "bill street", "bill city"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Name,Ship Street,Ship City,Bill Street,Bill City\n" +
+		"Ann,1 Main St,Springfield,,\n" +
+		"Bob,,,,"
+	if string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	var back []customer
+	if err := Unmarshal(b, &back, customerRel); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, in) {
+		t.Errorf("round trip = %+v, want %+v", back, in)
+	}
+}
+
+type node struct {
+	Value int   `csvform:"value"`
+	Next  *node `csvform:"next"`
+}
+
+type wrapper struct {
+	Head node `csvform:"head"`
+}
+
+func TestRecursiveTypes(t *testing.T) {
+	rel := map[string][]string{"value": {"Value"}}
+	check := func(what string, err error) {
+		if err == nil || !strings.Contains(err.Error(), "recursive type") {
+			t.Errorf("%s: got %v, want a recursive type error", what, err)
+		}
+	}
+
+	_, err := GetOptions(node{})
+	check("GetOptions", err)
+	_, err = Marshal([]node{{Value: 1}}, rel)
+	check("Marshal", err)
+	var out []node
+	check("Unmarshal", Unmarshal([]byte("Value\n1"), &out, rel))
+	var one wrapper
+	check("UnmarshalRow", UnmarshalRow(1, []byte("Value\n1"), &one, rel))
+}
@@ -0,0 +1,124 @@
+package form
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/xiphoid24/csv/internal/randtype"
+)
+
+// gen makes struct types with csvform tags, leaving some fields untagged
+// and reaching some nested structs through a pointer. The first field is
+// always tagged so every type has a column.
+var gen = randtype.Gen{
+	Tag: func(r *rand.Rand, i int) reflect.StructTag {
+		switch r.Intn(3) {
+		case 0:
+			return reflect.StructTag(fmt.Sprintf(`csvform:"Field %d"`, i))
+		case 1:
+			return `csvform:""`
+		}
+		if i == 0 {
+			return `csvform:""`
+		}
+		return ""
+	},
+	Skip: func(f reflect.StructField) bool {
+		_, ok := f.Tag.Lookup("csvform")
+		return !ok
+	},
+	Pointers: true,
+}
+
+// expected is what Unmarshal gives back for in: Marshal leaves out elements
+// that encode to an empty row, and a pointer to an empty struct reads back
+// as nil.
+func expected(in reflect.Value) reflect.Value {
+	want := reflect.MakeSlice(in.Type(), 0, in.Len())
+	for i := 0; i < in.Len(); i++ {
+		if !gen.Empty(in.Index(i)) {
+			v := reflect.New(in.Type().Elem()).Elem()
+			v.Set(in.Index(i))
+			clearEmpty(v)
+			want = reflect.Append(want, v)
+		}
+	}
+	return want
+}
+
+func clearEmpty(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			clearEmpty(v.Field(i))
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if gen.Empty(v) {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		clearEmpty(v.Elem())
+	}
+}
+
+// randRelation maps every option of t to a column with an awkward name.
+func randRelation(t reflect.Type) (map[string][]string, error) {
+	options, err := GetOptions(reflect.New(t).Elem().Interface())
+	if err != nil {
+		return nil, err
+	}
+	rel := map[string][]string{}
+	for i, opt := range options {
+		rel[opt] = []string{fmt.Sprintf(`col %d, "%s"`, i, opt)}
+	}
+	return rel, nil
+}
+
+// TestRoundTrip checks that Unmarshal reads back what Marshal writes, up to
+// the empty rows and sub-objects Marshal leaves out.
+func TestRoundTrip(t *testing.T) {
+	check := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		typ := gen.Type(r, 2)
+		rel, err := randRelation(typ)
+		if err != nil {
+			t.Logf("seed %d: GetOptions: %v", seed, err)
+			return false
+		}
+
+		in := gen.Slice(r, typ)
+
+		b, err := Marshal(in.Interface(), rel)
+		if err != nil {
+			t.Logf("seed %d: Marshal: %v", seed, err)
+			return false
+		}
+
+		// a header on its own is not enough rows for Unmarshal
+		want := expected(in)
+		if want.Len() == 0 {
+			return !bytes.Contains(b, []byte("\n"))
+		}
+
+		out := reflect.New(in.Type())
+		if err := Unmarshal(b, out.Interface(), rel); err != nil {
+			t.Logf("seed %d: Unmarshal: %v\n%s", seed, err, b)
+			return false
+		}
+		if !reflect.DeepEqual(want.Interface(), out.Elem().Interface()) {
+			t.Logf("seed %d: mismatch\n in: %+v\nout: %+v\n%s", seed, want, out.Elem(), b)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,191 @@
+package form
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// TransformFunc rewrites a single cell value. Transforms named in a relation
+// map entry are applied in order to the raw cell before type conversion when
+// decoding, and to the formatted value when encoding.
+//
+// A transform runs in both directions unless its name is prefixed with
+// "decode:" or "encode:", which limits it to that direction. Transforms
+// that cannot be undone, such as lastfirst, are usually paired with their
+// reverse:
+//
+//	{"Customer", "trim", "decode:lastfirst", "encode:firstlast"}
+type TransformFunc func(string) (string, error)
+
+// Directions a transform chain is applied in.
+const (
+	decodeDir = "decode"
+	encodeDir = "encode"
+)
+
+var (
+	transformMu sync.RWMutex
+	transforms  = map[string]TransformFunc{
+		"trim":       trim,
+		"upper":      upper,
+		"lower":      lower,
+		"title":      title,
+		"nospace":    nospace,
+		"nocurrency": nocurrency,
+		"digits":     digits,
+		"lastfirst":  lastfirst,
+		"firstlast":  firstlast,
+	}
+)
+
+// RegisterTransform makes fn available to relation maps under name, which
+// cannot contain a colon. It replaces any transform previously registered
+// under the same name, including the built-in ones.
+func RegisterTransform(name string, fn TransformFunc) {
+	if name == "" || fn == nil {
+		panic("csv/form: RegisterTransform with empty name or nil func")
+	}
+	if strings.Contains(name, ":") {
+		panic("csv/form: RegisterTransform name " + name + " contains a colon")
+	}
+	transformMu.Lock()
+	transforms[name] = fn
+	transformMu.Unlock()
+}
+
+func getTransform(name string) (TransformFunc, bool) {
+	transformMu.RLock()
+	fn, ok := transforms[name]
+	transformMu.RUnlock()
+	return fn, ok
+}
+
+// getTransforms returns the transform names that follow the column name
+// in a relation map entry.
+func getTransforms(key string, m map[string][]string) []string {
+	if m == nil {
+		return nil
+	}
+	ss, ok := m[key]
+	if !ok || len(ss) < 2 {
+		return nil
+	}
+	return ss[1:]
+}
+
+// applyTransforms runs the transforms of names that apply in dir, either
+// decodeDir or encodeDir.
+func applyTransforms(s string, names []string, dir string) (string, error) {
+	for _, name := range names {
+		if i := strings.Index(name, ":"); i >= 0 {
+			if name[:i] != decodeDir && name[:i] != encodeDir {
+				return "", fmt.Errorf("csv/form: unknown transform direction in %q", name)
+			}
+			if name[:i] != dir {
+				continue
+			}
+			name = name[i+1:]
+		}
+		fn, ok := getTransform(name)
+		if !ok {
+			return "", fmt.Errorf("csv/form: unknown transform %q", name)
+		}
+		var err error
+		if s, err = fn(s); err != nil {
+			return "", fmt.Errorf("csv/form: transform %q: %v", name, err)
+		}
+	}
+	return s, nil
+}
+
+func trim(s string) (string, error) {
+	return strings.TrimSpace(s), nil
+}
+
+func upper(s string) (string, error) {
+	return strings.ToUpper(s), nil
+}
+
+func lower(s string) (string, error) {
+	return strings.ToLower(s), nil
+}
+
+// title upper-cases the first letter of every word and lower-cases the rest.
+func title(s string) (string, error) {
+	rs := []rune(s)
+	start := true
+	for i, r := range rs {
+		if unicode.IsSpace(r) || r == '-' {
+			start = true
+			continue
+		}
+		if start {
+			rs[i] = unicode.ToUpper(r)
+		} else {
+			rs[i] = unicode.ToLower(r)
+		}
+		start = false
+	}
+	return string(rs), nil
+}
+
+func nospace(s string) (string, error) {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s), nil
+}
+
+// nocurrency drops currency symbols and the spaces around them, so
+// "$ 1,200" becomes "1,200" and "12 €" becomes "12".
+func nocurrency(s string) (string, error) {
+	s = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Sc, r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s), nil
+}
+
+// digits keeps only the decimal digits of s, which is handy for phone
+// numbers and other identifiers written with punctuation.
+func digits(s string) (string, error) {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s), nil
+}
+
+// lastfirst turns "Last, First" into "First Last". Values without a comma
+// are returned unchanged. It is meant for decoding, with firstlast as its
+// "encode:" counterpart.
+func lastfirst(s string) (string, error) {
+	i := strings.Index(s, ",")
+	if i < 0 {
+		return s, nil
+	}
+	last, first := strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	if first == "" {
+		return last, nil
+	}
+	return first + " " + last, nil
+}
+
+// firstlast turns "First Last" into "Last, First", splitting on the final
+// space. It is the reverse of lastfirst and is meant for encoding, with
+// lastfirst as its "decode:" counterpart.
+func firstlast(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndex(s, " ")
+	if i < 0 {
+		return s, nil
+	}
+	return strings.TrimSpace(s[i+1:]) + ", " + strings.TrimSpace(s[:i]), nil
+}
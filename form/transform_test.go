@@ -0,0 +1,98 @@
+package form
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTransforms(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"trim", "  a b  ", "a b"},
+		{"upper", "abc", "ABC"},
+		{"lower", "AbC", "abc"},
+		{"title", "mary-ann o'NEIL", "Mary-Ann O'neil"},
+		{"nospace", " a b\tc ", "abc"},
+		{"nocurrency", "$ 1,200", "1,200"},
+		{"nocurrency", "12 €", "12"},
+		{"digits", "(555) 123-4567", "5551234567"},
+		{"lastfirst", "Doe, John", "John Doe"},
+		{"lastfirst", "Doe,", "Doe"},
+		{"lastfirst", "John", "John"},
+		{"firstlast", "John Doe", "Doe, John"},
+		{"firstlast", "John", "John"},
+	}
+	for _, tt := range tests {
+		fn, ok := getTransform(tt.name)
+		if !ok {
+			t.Fatalf("%s: not registered", tt.name)
+		}
+		got, err := fn(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("%s(%q) = %q, %v, want %q", tt.name, tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestTransformDirections(t *testing.T) {
+	type person struct {
+		Name string `csvform:"name"`
+	}
+	rel := map[string][]string{"name": {"Customer", "trim", "decode:lastfirst", "encode:firstlast"}}
+
+	var out []person
+	if err := Unmarshal([]byte("Customer\n\" Doe, John \""), &out, rel); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].Name != "John Doe" {
+		t.Fatalf("Unmarshal = %+v, want John Doe", out)
+	}
+
+	b, err := Marshal(out, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Customer\n\"Doe, John\""; string(b) != want {
+		t.Errorf("Marshal = %q, want %q", b, want)
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	type row struct {
+		Name string `csvform:"name"`
+	}
+	for _, name := range []string{"nosuch", "both:trim", "decode:nosuch"} {
+		rel := map[string][]string{"name": {"Name", name}}
+		var out []row
+		err := Unmarshal([]byte("Name\nx"), &out, rel)
+		if err == nil || !strings.Contains(err.Error(), "csv/form:") {
+			t.Errorf("%s: got %v, want a transform error", name, err)
+		}
+	}
+}
+
+func TestRegisterTransform(t *testing.T) {
+	RegisterTransform("test-reverse", func(s string) (string, error) {
+		rs := []rune(s)
+		for i, j := 0, len(rs)-1; i < j; i, j = i+1, j-1 {
+			rs[i], rs[j] = rs[j], rs[i]
+		}
+		return string(rs), nil
+	})
+	got, err := applyTransforms("abc", []string{"encode:test-reverse", "upper"}, encodeDir)
+	if err != nil || got != "CBA" {
+		t.Errorf("got %q, %v, want CBA", got, err)
+	}
+	got, err = applyTransforms("abc", []string{"encode:test-reverse", "upper"}, decodeDir)
+	if err != nil || got != "ABC" {
+		t.Errorf("got %q, %v, want ABC", got, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a name with a colon")
+		}
+	}()
+	RegisterTransform("a:b", trim)
+}
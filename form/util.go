@@ -8,11 +8,16 @@ import (
 func GetOptions(v interface{}) ([]string, error) {
 	var options []string
 
-	val := reflect.ValueOf(v)
+	val := reflect.Indirect(reflect.ValueOf(v))
 	if val.Kind() != reflect.Struct {
 		return nil, fmt.Errorf("csv error: expected a struct or a list of struct\n")
 	}
-	getOptions(val, &options, "")
+	if err := checkRecursive(val.Type()); err != nil {
+		return nil, err
+	}
+	if err := getOptions(val, &options, ""); err != nil {
+		return nil, err
+	}
 	return options, nil
 }
 
@@ -46,6 +51,13 @@ func getOptions(strctVal reflect.Value, s *[]string, start string) error {
 			if err := getOptions(reflect.Indirect(fld), s, start+tag); err != nil {
 				return err
 			}
+		case reflect.Ptr:
+			if !isStructPtr(fld.Type()) {
+				continue
+			}
+			if err := getOptions(reflect.New(fld.Type().Elem()).Elem(), s, start+tag); err != nil {
+				return err
+			}
 		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64, reflect.Bool:
 			(*s) = append((*s), start+tag)
 		}
@@ -54,6 +66,9 @@ func getOptions(strctVal reflect.Value, s *[]string, start string) error {
 	return nil
 }
 
+// getColumnName returns the column a relation map entry points at. An entry
+// is the column name followed by the names of any transforms to apply, e.g.
+// {"Customer Name", "trim", "lastfirst"}.
 func getColumnName(key string, m map[string][]string) (string, bool) {
 	if m == nil {
 		return "", false
@@ -64,3 +79,39 @@ func getColumnName(key string, m map[string][]string) (string, bool) {
 	}
 	return ss[0], ss[0] != ""
 }
+
+func isStructPtr(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+}
+
+// checkRecursive returns an error when a csvform-tagged field of t leads
+// back to a struct type that contains it, such as Next *Node inside Node.
+// The header and row traversals would never end for such a type.
+func checkRecursive(t reflect.Type) error {
+	return walkTypes(t, map[reflect.Type]bool{})
+}
+
+func walkTypes(t reflect.Type, path map[reflect.Type]bool) error {
+	if path[t] {
+		return fmt.Errorf("csv/form: recursive type %s", t)
+	}
+	path[t] = true
+	defer delete(path, t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup("csvform"); !ok {
+			continue
+		}
+		ft := f.Type
+		if isStructPtr(ft) {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			continue
+		}
+		if err := walkTypes(ft, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
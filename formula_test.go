@@ -0,0 +1,29 @@
+package csv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEscapeFormulas(t *testing.T) {
+	type row struct {
+		Name string
+		Qty  int
+	}
+	in := []row{{"=HYPERLINK(\"x\")", -1}, {"+1", 2}, {"'=quoted", 3}, {"'plain", 4}, {"\tTab", 5}, {"ok", 6}}
+	b, err := Marshal(in, EscapeFormulas())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Name,Qty\n\"'=HYPERLINK(\"\"x\"\")\",-1\n'+1,2\n''=quoted,3\n'plain,4\n'\tTab,5\nok,6"
+	if string(b) != want {
+		t.Errorf("Marshal = %q, want %q", b, want)
+	}
+	var out []row
+	if err := Unmarshal(b, &out, UnescapeFormulas()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Unmarshal = %q, want %q", out, in)
+	}
+}
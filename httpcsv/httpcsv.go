@@ -0,0 +1,199 @@
+// Package httpcsv implements the common parts of CSV upload and download
+// endpoints: reading a CSV body or multipart file into a slice of structs,
+// streaming a CSV attachment, and reporting failures as JSON.
+package httpcsv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/xiphoid24/csv"
+)
+
+// DefaultMaxBytes is the largest CSV file DecodeRequest accepts when the
+// decoder options do not set Limits.MaxBytes.
+const DefaultMaxBytes = 10 << 20
+
+// Error is a failed request, written by WriteError as a JSON object with
+// an "error" message and, when known, the "row" and "column" at fault.
+type Error struct {
+	Status  int    `json:"-"`
+	Message string `json:"error"`
+	Row     int    `json:"row,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Err     error  `json:"-"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// DecodeRequest decodes the CSV file of r into v, a pointer to a slice of
+// structs. The file is the first file part of a multipart/form-data
+// request, or else the request body. It is decoded as it is read, so an
+// upload over the decoder's Limits is cut off early; files over
+// DefaultMaxBytes are rejected unless csv.WithLimits sets MaxBytes.
+// Failures are returned as an *Error with the status to answer with: 413
+// for files over the Limits, 415 for other content types, 422 for rows that
+// do not decode and 400 for anything else wrong with the request.
+func DecodeRequest(r *http.Request, v interface{}, opts ...csv.DecoderOption) error {
+	body, err := requestFile(r)
+	if err != nil {
+		return err
+	}
+	var d csv.CSVDecoder
+	for _, opt := range opts {
+		opt(&d)
+	}
+	if d.Limits.MaxBytes == 0 {
+		d.Limits.MaxBytes = DefaultMaxBytes
+		opts = append(opts[:len(opts):len(opts)], csv.WithLimits(d.Limits))
+	}
+
+	br := &bodyReader{r: body}
+	if err := csv.UnmarshalReader(br, v, opts...); err != nil {
+		if br.err != nil {
+			return &Error{Status: http.StatusBadRequest, Message: "httpcsv: reading request: " + br.err.Error(), Err: br.err}
+		}
+		return decodeError(err)
+	}
+	return nil
+}
+
+// bodyReader records a failed read of the request, which is not the
+// client's CSV being invalid.
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// requestFile returns the reader of the CSV file sent with r.
+func requestFile(r *http.Request) (io.Reader, error) {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return r.Body, nil
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Message: "httpcsv: " + err.Error(), Err: err}
+	}
+	switch mt {
+	case "text/csv", "application/csv", "text/plain", "application/octet-stream":
+		return r.Body, nil
+	case "multipart/form-data":
+	default:
+		return nil, &Error{Status: http.StatusUnsupportedMediaType, Message: fmt.Sprintf("httpcsv: unsupported content type %q", mt)}
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Message: "httpcsv: " + err.Error(), Err: err}
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, &Error{Status: http.StatusBadRequest, Message: "httpcsv: no file in form"}
+		}
+		if err != nil {
+			return nil, &Error{Status: http.StatusBadRequest, Message: "httpcsv: " + err.Error(), Err: err}
+		}
+		if part.FileName() != "" {
+			return part, nil
+		}
+	}
+}
+
+// decodeError wraps an Unmarshal error, picking out the row and column.
+func decodeError(err error) *Error {
+	e := &Error{Status: http.StatusUnprocessableEntity, Message: err.Error(), Err: err}
+	var rowErr *csv.RowError
+	if errors.As(err, &rowErr) {
+		e.Row = rowErr.Row
+	}
+	var rangeErr *csv.RangeError
+	if errors.As(err, &rangeErr) {
+		e.Column = rangeErr.Column
+	}
+	var valueErr *csv.ValueError
+	if errors.As(err, &valueErr) {
+		e.Column = valueErr.Column
+	}
+	var limitErr *csv.LimitError
+	if errors.As(err, &limitErr) {
+		e.Status, e.Row = http.StatusRequestEntityTooLarge, limitErr.Row
+	}
+	var invalid *csv.InvalidUnmarshalError
+	if errors.As(err, &invalid) {
+		e.Status = http.StatusInternalServerError
+	}
+	return e
+}
+
+// WriteResponse streams v, a struct or a slice of structs, as a CSV
+// attachment named filename, with Content-Encoding gzip when opts include
+// csv.GzipOutput. When encoding fails before anything is written the error
+// is answered with WriteError; otherwise the response is cut short and the
+// error returned for the caller to log.
+func WriteResponse(w http.ResponseWriter, filename string, v interface{}, opts ...csv.EncoderOption) error {
+	h := w.Header()
+	h.Set("Content-Type", "text/csv; charset=utf-8")
+	if filename != "" {
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+
+	var enc csv.CSVEncoder
+	for _, opt := range opts {
+		opt(&enc)
+	}
+	if enc.Gzip {
+		h.Set("Content-Encoding", "gzip")
+	}
+
+	cw := &countWriter{w: w}
+	err := csv.MarshalTo(cw, v, opts...)
+	if err != nil && cw.n == 0 {
+		h.Del("Content-Disposition")
+		h.Del("Content-Encoding")
+		WriteError(w, err)
+	}
+	return err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// WriteError answers a request with err as JSON. An *Error keeps its
+// status, any other error is answered with 500.
+func WriteError(w http.ResponseWriter, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Status: http.StatusInternalServerError, Message: err.Error(), Err: err}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(e)
+}
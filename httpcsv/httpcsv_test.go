@@ -0,0 +1,229 @@
+package httpcsv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xiphoid24/csv"
+)
+
+type item struct {
+	Name string `csv:"name"`
+	Qty  int    `csv:"qty,max=10"`
+}
+
+// upload serves DecodeRequest, answering with the decoded items or the
+// error as JSON.
+func upload(w http.ResponseWriter, r *http.Request, opts ...csv.DecoderOption) {
+	var items []item
+	if err := DecodeRequest(r, &items, opts...); err != nil {
+		WriteError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(items)
+}
+
+func serve(req *http.Request, opts ...csv.DecoderOption) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	upload(rec, req, opts...)
+	return rec
+}
+
+func TestDecodeRequestBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader("name,qty\na,1\nb,2"))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := serve(req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var got []item
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	want := []item{{"a", 1}, {"b", 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeRequestMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("note", "ignored")
+	fw, _ := mw.CreateFormFile("file", "items.csv")
+	fw.Write([]byte("name,qty\nc,3"))
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var got []item
+	if err := DecodeRequest(req, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := []item{{"c", 3}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecodeRequestErrors(t *testing.T) {
+	limits := csv.WithLimits(csv.Limits{MaxBytes: 32})
+
+	var empty bytes.Buffer
+	mw := multipart.NewWriter(&empty)
+	mw.WriteField("note", "no file")
+	mw.Close()
+
+	tests := []struct {
+		name, contentType, body string
+		status, row             int
+		column                  string
+	}{
+		{"bad number", "text/csv", "name,qty\na,1\nb,x", http.StatusUnprocessableEntity, 2, "qty"},
+		{"over max", "text/csv", "name,qty\na,11", http.StatusUnprocessableEntity, 1, "qty"},
+		{"too large", "text/csv", "name,qty\n" + strings.Repeat("a,1\n", 10), http.StatusRequestEntityTooLarge, 0, ""},
+		{"content type", "application/json", "[]", http.StatusUnsupportedMediaType, 0, ""},
+		{"no file", mw.FormDataContentType(), empty.String(), http.StatusBadRequest, 0, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := serve(req, limits)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s: content type %q", tt.name, ct)
+		}
+		var e struct {
+			Error  string `json:"error"`
+			Row    int    `json:"row"`
+			Column string `json:"column"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if e.Error == "" || e.Row != tt.row || e.Column != tt.column {
+			t.Errorf("%s: got %+v, want row %d column %q", tt.name, e, tt.row, tt.column)
+		}
+	}
+}
+
+// endless is a CSV body that never ends, counting the bytes read from it.
+type endless struct {
+	n int
+}
+
+func (e *endless) Read(p []byte) (int, error) {
+	row := "name,qty\n"
+	if e.n > 0 {
+		row = "a,1\n"
+	}
+	n := copy(p, row)
+	e.n += n
+	return n, nil
+}
+
+func TestDecodeRequestStreams(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []csv.DecoderOption
+		limit string
+		max   int
+	}{
+		{"default", nil, "MaxBytes", DefaultMaxBytes},
+		{"max rows", []csv.DecoderOption{csv.WithLimits(csv.Limits{MaxRows: 100})}, "MaxRows", 1 << 12},
+		{"max bytes", []csv.DecoderOption{csv.WithLimits(csv.Limits{MaxBytes: 1 << 10})}, "MaxBytes", 1 << 12},
+	}
+	for _, tt := range tests {
+		body := &endless{}
+		req := httptest.NewRequest("POST", "/", body)
+		var items []item
+		err := DecodeRequest(req, &items, tt.opts...)
+		var e *Error
+		var limitErr *csv.LimitError
+		if !errors.As(err, &e) || e.Status != http.StatusRequestEntityTooLarge || !errors.As(err, &limitErr) || limitErr.Limit != tt.limit {
+			t.Errorf("%s: error = %v, want %s exceeded", tt.name, err, tt.limit)
+		}
+		// the reader buffers ahead a little, but stops near the limit
+		if body.n > tt.max+1<<16 {
+			t.Errorf("%s: read %d bytes, want about %d", tt.name, body.n, tt.max)
+		}
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestDecodeRequestReadError(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", io.MultiReader(strings.NewReader("name,qty\na,1\n"), failingReader{}))
+	rec := serve(req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	items := []item{{"a", 1}, {"b, c", 2}}
+	if err := WriteResponse(rec, "items 2024.csv", items); err != nil {
+		t.Fatal(err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("content type %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="items 2024.csv"` {
+		t.Errorf("content disposition %q", cd)
+	}
+	if got, want := rec.Body.String(), "name,qty\na,1\n\"b, c\",2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriteResponseGzip(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := WriteResponse(rec, "items.csv", []item{{"a", 1}}, csv.GzipOutput()); err != nil {
+		t.Fatal(err)
+	}
+	if ce := rec.Header().Get("Content-Encoding"); ce != "gzip" {
+		t.Errorf("content encoding %q, want gzip", ce)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(zr); err != nil || string(b) != "name,qty\na,1" {
+		t.Errorf("got %q, %v", b, err)
+	}
+
+	rec = httptest.NewRecorder()
+	if err := WriteResponse(rec, "x.csv", []int{1}, csv.GzipOutput()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if ce := rec.Header().Get("Content-Encoding"); ce != "" {
+		t.Errorf("content encoding %q on an error response", ce)
+	}
+}
+
+func TestWriteResponseError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := WriteResponse(rec, "x.csv", []int{1}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Disposition") != "" {
+		t.Error("Content-Disposition set on an error response")
+	}
+}
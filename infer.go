@@ -0,0 +1,378 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// delimiters are the separators InferSchema tries, in order of preference.
+var delimiters = []rune{',', ';', '\t', '|'}
+
+// timeLayouts are the layouts InferSchema tries for time columns.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02",
+	"01/02/2006",
+	"02/01/2006",
+	"02.01.2006",
+	"15:04:05",
+}
+
+// boolPairs are the true and false spellings InferSchema recognizes in
+// addition to the ones strconv.ParseBool accepts.
+var boolPairs = [][2]string{{"yes", "no"}, {"y", "n"}, {"on", "off"}}
+
+// InferSchema guesses the schema of the CSV data in r from its first
+// sampleRows data rows, or from all of them when sampleRows is zero or
+// less. It detects the delimiter, whether the first row is a header, and
+// the type of each column. Columns with an empty cell are nullable, and
+// zero-padded numbers such as ZIP codes are kept as strings.
+func InferSchema(r io.Reader, sampleRows int) (*Schema, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimPrefix(b, []byte("\ufeff"))
+
+	comma, rows := sniffDelimiter(b, sampleRows)
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("csv: no rows to infer a schema from")
+	}
+
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+
+	s := &Schema{Columns: make([]Column, width)}
+	if comma != ',' {
+		s.Delimiter = string(comma)
+	}
+	for i := range s.Columns {
+		s.Columns[i] = inferColumn(columnCells(rows[1:], i))
+	}
+
+	if !isHeader(rows[0], rows[1:], s.Columns) {
+		s.NoHeader = true
+		if sampleRows > 0 && len(rows) > sampleRows {
+			rows = rows[:sampleRows]
+		}
+		for i := range s.Columns {
+			s.Columns[i] = inferColumn(columnCells(rows, i))
+		}
+	}
+	for i := range s.Columns {
+		if !s.NoHeader && i < len(rows[0]) && rows[0][i] != "" {
+			s.Columns[i].Name = rows[0][i]
+		} else {
+			s.Columns[i].Name = "column" + strconv.Itoa(i+1)
+		}
+	}
+	return s, nil
+}
+
+// sniffDelimiter returns the delimiter that splits the most rows into the
+// same number of fields, along with the first sampleRows+1 rows read with
+// it.
+func sniffDelimiter(b []byte, sampleRows int) (rune, [][]string) {
+	best, bestRows, bestScore := ',', [][]string(nil), -1
+	for _, comma := range delimiters {
+		rdr := csv.NewReader(bytes.NewReader(b))
+		rdr.Comma = comma
+		rdr.FieldsPerRecord = -1
+		rdr.LazyQuotes = true
+
+		var rows [][]string
+		for sampleRows <= 0 || len(rows) <= sampleRows {
+			row, err := rdr.Read()
+			if err != nil {
+				break
+			}
+			rows = append(rows, row)
+		}
+
+		score := 0
+		if len(rows) > 0 && len(rows[0]) > 1 {
+			for _, row := range rows {
+				if len(row) == len(rows[0]) {
+					score++
+				}
+			}
+		}
+		if score > bestScore {
+			best, bestRows, bestScore = comma, rows, score
+		}
+	}
+	return best, bestRows
+}
+
+func columnCells(rows [][]string, i int) []string {
+	cells := make([]string, len(rows))
+	for n, row := range rows {
+		if i < len(row) {
+			cells[n] = row[i]
+		}
+	}
+	return cells
+}
+
+// isHeader reports whether first looks like a header for the data rows in
+// body. A header has distinct, non-empty cells that do not parse as the
+// types of the columns below them.
+func isHeader(first []string, body [][]string, cols []Column) bool {
+	seen := make(map[string]bool, len(first))
+	for _, cell := range first {
+		if strings.TrimSpace(cell) == "" || seen[cell] {
+			return false
+		}
+		seen[cell] = true
+	}
+
+	typed, matched := 0, 0
+	for i, cell := range first {
+		if cols[i].Type == TypeString {
+			continue
+		}
+		typed++
+		if inferColumn([]string{cell}).Type == cols[i].Type {
+			matched++
+		}
+	}
+	if typed > 0 {
+		return matched < typed-matched
+	}
+
+	if len(body) == 0 {
+		for _, cell := range first {
+			if inferColumn([]string{cell}).Type != TypeString {
+				return false
+			}
+		}
+		return true
+	}
+
+	// with only string columns, a header does not repeat its column's values
+	for i, cell := range first {
+		for _, row := range body {
+			if i < len(row) && row[i] == cell {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// inferColumn picks the narrowest type that every non-empty cell parses as.
+func inferColumn(cells []string) Column {
+	col := Column{Type: TypeString}
+	var values []string
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) == "" {
+			col.Nullable = true
+			continue
+		}
+		values = append(values, strings.TrimSpace(cell))
+	}
+	if len(values) == 0 {
+		col.Nullable = true
+		return col
+	}
+
+	switch {
+	case all(values, isInt):
+		col.Type = TypeInt
+	case all(values, isFloat):
+		col.Type = TypeFloat
+	case all(values, isBool):
+		col.Type = TypeBool
+	default:
+		for _, pair := range boolPairs {
+			if all(values, func(v string) bool {
+				return strings.EqualFold(v, pair[0]) || strings.EqualFold(v, pair[1])
+			}) {
+				col.Type, col.Format = TypeBool, pair[0]+"/"+pair[1]
+				return col
+			}
+		}
+		for _, layout := range timeLayouts {
+			if all(values, func(v string) bool {
+				_, err := time.Parse(layout, v)
+				return err == nil
+			}) {
+				col.Type = TypeTime
+				if layout != time.RFC3339 {
+					col.Format = layout
+				}
+				return col
+			}
+		}
+	}
+	return col
+}
+
+func all(values []string, fn func(string) bool) bool {
+	for _, v := range values {
+		if !fn(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func isInt(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && !zeroPadded(s)
+}
+
+func isFloat(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil && strings.ContainsAny(s, "0123456789") && !zeroPadded(s)
+}
+
+// zeroPadded reports whether s has a leading zero before another digit, as
+// in "007" or "-01.5", which a number column would not write back.
+func zeroPadded(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 1 && s[0] == '0' && s[1] >= '0' && s[1] <= '9'
+}
+
+func isBool(s string) bool {
+	_, err := strconv.ParseBool(s)
+	return err == nil
+}
+
+// goTypes are the field types GoStruct writes, indexed by nullability.
+var goTypes = map[ColumnType][2]string{
+	TypeString: {"string", "sql.NullString"},
+	TypeInt:    {"int64", "sql.NullInt64"},
+	TypeUint:   {"uint64", "sql.Null[uint64]"},
+	TypeFloat:  {"float64", "sql.NullFloat64"},
+	TypeBool:   {"bool", "sql.NullBool"},
+	TypeTime:   {"time.Time", "sql.NullTime"},
+}
+
+// GoStruct returns the source of a struct type called name whose fields
+// and csv tags decode the schema's columns with Unmarshal. Column names
+// that cannot be written in a csv tag are matched with a csvlabel tag.
+// Unmarshal needs a header row, so files with NoHeader set need one added
+// first, and files with a Delimiter need the ParseDelimiter option.
+// The imports the fields need are written above the type; nullable uint
+// columns use sql.Null[uint64], which needs Go 1.22.
+func (s *Schema) GoStruct(name string) string {
+	var b strings.Builder
+	var imports []string
+	for _, col := range s.Columns {
+		switch {
+		case col.Nullable:
+			imports = appendImport(imports, "database/sql")
+		case col.Type == TypeTime:
+			imports = appendImport(imports, "time")
+		}
+	}
+	if len(imports) > 0 {
+		sort.Strings(imports)
+		b.WriteString("import (\n")
+		for _, path := range imports {
+			fmt.Fprintf(&b, "\t%q\n", path)
+		}
+		b.WriteString(")\n\n")
+	}
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+
+	seen := map[string]bool{}
+	for i, col := range s.Columns {
+		field := goName(col.Name, i)
+		for n := 2; seen[field]; n++ {
+			field = goName(col.Name, i) + strconv.Itoa(n)
+		}
+		seen[field] = true
+
+		typ, ok := goTypes[col.Type]
+		if !ok {
+			typ = goTypes[TypeString]
+		}
+		goType := typ[0]
+		if col.Nullable {
+			goType = typ[1]
+		}
+
+		path, label := col.Name, ""
+		if path == "" || path == "-" || strings.Contains(path, ",") {
+			path, label = field, col.Name
+		}
+		opts, err := col.tag(i)
+		if err != nil {
+			opts = columnPath(i)
+		}
+		tag := fmt.Sprintf("csv:%q", path+strings.TrimPrefix(opts, columnPath(i)))
+		if label != "" {
+			tag += fmt.Sprintf(" csvlabel:%q", label)
+		}
+		if strings.Contains(tag, "`") {
+			tag = strconv.Quote(tag)
+		} else {
+			tag = "`" + tag + "`"
+		}
+		fmt.Fprintf(&b, "\t%s %s %s\n", field, goType, tag)
+	}
+	b.WriteString("}\n")
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return b.String()
+	}
+	return string(src)
+}
+
+func appendImport(imports []string, path string) []string {
+	for _, p := range imports {
+		if p == path {
+			return imports
+		}
+	}
+	return append(imports, path)
+}
+
+// initialisms are written in upper case in field names.
+var initialisms = map[string]bool{
+	"api": true, "http": true, "id": true, "ip": true, "json": true,
+	"sku": true, "sql": true, "uri": true, "url": true, "uuid": true,
+}
+
+// goName turns a column name like "customer e-mail" into an exported
+// identifier like CustomerEMail.
+func goName(col string, i int) string {
+	words := strings.FieldsFunc(col, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		if initialisms[strings.ToLower(w)] {
+			b.WriteString(strings.ToUpper(w))
+			continue
+		}
+		r := []rune(w)
+		b.WriteString(strings.ToUpper(string(r[0])) + string(r[1:]))
+	}
+	name := b.String()
+	switch {
+	case name == "":
+		return "Column" + strconv.Itoa(i+1)
+	case !unicode.IsLetter([]rune(name)[0]) || !unicode.IsUpper([]rune(name)[0]):
+		return "Col" + name
+	}
+	return name
+}
@@ -0,0 +1,106 @@
+package csv
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Schema
+	}{
+		{
+			name: "types",
+			in:   "id,price,ok,seen,day\n1,2.5,true,yes,2024-01-02\n-3,4,false,no,2024-02-03\n",
+			want: Schema{Columns: []Column{
+				{Name: "id", Type: TypeInt},
+				{Name: "price", Type: TypeFloat},
+				{Name: "ok", Type: TypeBool},
+				{Name: "seen", Type: TypeBool, Format: "yes/no"},
+				{Name: "day", Type: TypeTime, Format: "2006-01-02"},
+			}},
+		},
+		{
+			name: "zero padded",
+			in:   "zip,code,n\n02134,0.5,7\n90210,00.5,-08\n",
+			want: Schema{Columns: []Column{
+				{Name: "zip", Type: TypeString},
+				{Name: "code", Type: TypeString},
+				{Name: "n", Type: TypeString},
+			}},
+		},
+		{
+			name: "nullable",
+			in:   "a,b\n1,\n,x\n",
+			want: Schema{Columns: []Column{
+				{Name: "a", Type: TypeInt, Nullable: true},
+				{Name: "b", Type: TypeString, Nullable: true},
+			}},
+		},
+		{
+			name: "delimiter without header",
+			in:   "1;x;2024-01-02\n2;y;2024-01-03\n",
+			want: Schema{Delimiter: ";", NoHeader: true, Columns: []Column{
+				{Name: "column1", Type: TypeInt},
+				{Name: "column2", Type: TypeString},
+				{Name: "column3", Type: TypeTime, Format: "2006-01-02"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InferSchema(strings.NewReader(tt.in), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("InferSchema = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	if _, err := InferSchema(strings.NewReader(""), 0); err == nil {
+		t.Error("InferSchema of empty input: no error")
+	}
+}
+
+func TestGoStruct(t *testing.T) {
+	s := Schema{Columns: []Column{
+		{Name: "user id", Type: TypeInt},
+		{Name: "count", Type: TypeUint, Nullable: true},
+		{Name: "a,b", Type: TypeString},
+		{Name: "when", Type: TypeTime, Format: "Jan 2, 2006"},
+		{Name: "ok", Type: TypeBool, Format: "Y/N", Nullable: true},
+	}}
+	src := s.GoStruct("Row")
+	want := `import (
+	"database/sql"
+	"time"
+)
+
+type Row struct {
+	UserID int64            ` + "`" + `csv:"user id"` + "`" + `
+	Count  sql.Null[uint64] ` + "`" + `csv:"count"` + "`" + `
+	AB     string           ` + "`" + `csv:"AB" csvlabel:"a,b"` + "`" + `
+	When   time.Time        ` + "`" + `csv:"when,layout='Jan 2, 2006'"` + "`" + `
+	Ok     sql.NullBool     ` + "`" + `csv:"ok,true=Y,false=N"` + "`" + `
+}
+`
+	if src != want {
+		t.Errorf("GoStruct =\n%s\nwant\n%s", src, want)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+src, 0); err != nil {
+		t.Errorf("GoStruct does not parse: %v", err)
+	}
+
+	// without nullable or time columns there is nothing to import
+	src = (&Schema{Columns: []Column{{Name: "a", Type: TypeString}}}).GoStruct("Row")
+	if strings.Contains(src, "import") {
+		t.Errorf("GoStruct =\n%s\nwant no imports", src)
+	}
+}
@@ -0,0 +1,355 @@
+// Code generated by "csvgen -type Order,Contact -o gen_csv.go"; DO NOT EDIT.
+
+package gentest
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xiphoid24/csv"
+)
+
+// MarshalCSVRow implements csv.RowMarshaler.
+func (v Order) MarshalCSVRow() ([]string, error) {
+	var cells []string
+	cells = append(cells, strconv.FormatInt(v.ID, 10))
+	cells = append(cells, v.Ref)
+	cells = append(cells, strconv.FormatUint(uint64(v.Qty), 10))
+	cells = append(cells, strconv.FormatInt(int64(v.Mask), 16))
+	cells = append(cells, strconv.FormatFloat(v.Price, 'f', 2, 64))
+	cells = append(cells, strconv.FormatFloat(float64(v.Ratio), 'g', -1, 32))
+	if v.Paid {
+		cells = append(cells, "Y")
+	} else {
+		cells = append(cells, "N")
+	}
+	cells = append(cells, v.Placed.Format("2006-01-02"))
+	if !v.Note.Valid {
+		cells = append(cells, "")
+	} else {
+		cells = append(cells, v.Note.String)
+	}
+	if !v.Count.Valid {
+		cells = append(cells, "")
+	} else {
+		cells = append(cells, strconv.FormatInt(v.Count.Int64, 10))
+	}
+	if !v.Shipped.Valid {
+		cells = append(cells, "")
+	} else {
+		cells = append(cells, v.Shipped.Time.Format(time.RFC3339))
+	}
+	cells = append(cells, string(v.Status))
+	cells = append(cells, v.Ship.City)
+	cells = append(cells, v.Ship.Zip)
+	return cells, nil
+}
+
+// UnmarshalCSVRow implements csv.RowUnmarshaler.
+func (v *Order) UnmarshalCSVRow(header map[string]int, row []string) (bool, error) {
+	cell := func(path, label string) (string, bool) {
+		i, ok := header[path]
+		if !ok && label != "" {
+			i, ok = header[label]
+		}
+		if !ok {
+			return "", false
+		}
+		if i >= len(row) {
+			return "", true
+		}
+		return row[i], true
+	}
+	filled := false
+	if s, ok := cell("id", ""); ok && s != "" {
+		filled = true
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return filled, &csv.RangeError{Column: "id", Value: s, Type: reflect.TypeOf(v.ID)}
+			}
+			return filled, &csv.ValueError{Column: "id", Value: s, Err: errors.New("must be a number")}
+		}
+		v.ID = n
+	}
+	if s, ok := cell("ref", "Reference"); ok && s != "" {
+		filled = true
+		v.Ref = s
+	}
+	if s, ok := cell("qty", ""); ok && s != "" {
+		filled = true
+		n, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return filled, &csv.RangeError{Column: "qty", Value: s, Type: reflect.TypeOf(v.Qty)}
+			}
+			return filled, &csv.ValueError{Column: "qty", Value: s, Err: errors.New("must be a number")}
+		}
+		v.Qty = uint16(n)
+		if uint64(v.Qty) < 1 {
+			return filled, &csv.RangeError{Column: "qty", Value: s, Type: reflect.TypeOf(v.Qty), Min: "1"}
+		}
+		if uint64(v.Qty) > 500 {
+			return filled, &csv.RangeError{Column: "qty", Value: s, Type: reflect.TypeOf(v.Qty), Max: "500"}
+		}
+	}
+	if s, ok := cell("mask", ""); ok && s != "" {
+		filled = true
+		n, err := strconv.ParseInt(s, 16, 32)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return filled, &csv.RangeError{Column: "mask", Value: s, Type: reflect.TypeOf(v.Mask)}
+			}
+			return filled, &csv.ValueError{Column: "mask", Value: s, Err: errors.New("must be a number")}
+		}
+		v.Mask = int32(n)
+	}
+	if s, ok := cell("price", ""); ok && s != "" {
+		filled = true
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return filled, &csv.RangeError{Column: "price", Value: s, Type: reflect.TypeOf(v.Price)}
+			}
+			return filled, &csv.ValueError{Column: "price", Value: s, Err: errors.New("must be a number")}
+		}
+		v.Price = f
+	}
+	if s, ok := cell("ratio", ""); ok && s != "" {
+		filled = true
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return filled, &csv.RangeError{Column: "ratio", Value: s, Type: reflect.TypeOf(v.Ratio)}
+			}
+			return filled, &csv.ValueError{Column: "ratio", Value: s, Err: errors.New("must be a number")}
+		}
+		v.Ratio = float32(f)
+		if math.IsNaN(float64(v.Ratio)) || float64(v.Ratio) < 0 {
+			return filled, &csv.RangeError{Column: "ratio", Value: s, Type: reflect.TypeOf(v.Ratio), Min: "0"}
+		}
+		if math.IsNaN(float64(v.Ratio)) || float64(v.Ratio) > 1 {
+			return filled, &csv.RangeError{Column: "ratio", Value: s, Type: reflect.TypeOf(v.Ratio), Max: "1"}
+		}
+	}
+	if s, ok := cell("paid", ""); ok && s != "" {
+		filled = true
+		switch {
+		case strings.EqualFold(s, "Y"):
+			v.Paid = true
+		case strings.EqualFold(s, "N"):
+			v.Paid = false
+		default:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return filled, &csv.ValueError{Column: "paid", Value: s, Err: errors.New("must be true or false")}
+			}
+			v.Paid = b
+		}
+	}
+	if s, ok := cell("placed", ""); ok && s != "" {
+		filled = true
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return filled, &csv.ValueError{Column: "placed", Value: s, Err: errors.New("must be a time")}
+		}
+		v.Placed = t
+	}
+	if s, ok := cell("note", ""); ok {
+		if s == "" {
+			v.Note = sql.NullString{}
+		} else {
+			filled = true
+			v.Note.String = s
+			v.Note.Valid = true
+		}
+	}
+	if s, ok := cell("count", ""); ok {
+		if s == "" {
+			v.Count = sql.NullInt64{}
+		} else {
+			filled = true
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+					return filled, &csv.RangeError{Column: "count", Value: s, Type: reflect.TypeOf(v.Count.Int64)}
+				}
+				return filled, &csv.ValueError{Column: "count", Value: s, Err: errors.New("must be a number")}
+			}
+			v.Count.Int64 = n
+			v.Count.Valid = true
+		}
+	}
+	if s, ok := cell("shipped", ""); ok {
+		if s == "" {
+			v.Shipped = sql.NullTime{}
+		} else {
+			filled = true
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return filled, &csv.ValueError{Column: "shipped", Value: s, Err: errors.New("must be a time")}
+			}
+			v.Shipped.Time = t
+			v.Shipped.Valid = true
+		}
+	}
+	if s, ok := cell("status", ""); ok && s != "" {
+		filled = true
+		v.Status = Status(s)
+	}
+	if s, ok := cell("ship.city", ""); ok && s != "" {
+		filled = true
+		v.Ship.City = s
+	}
+	if s, ok := cell("ship.zip", ""); ok && s != "" {
+		filled = true
+		v.Ship.Zip = s
+	}
+	return filled, nil
+}
+
+// MarshalCSVForm implements form.RowMarshaler.
+func (v Contact) MarshalCSVForm(set func(key, val string) error) error {
+	if err := set("Name", v.Name); err != nil {
+		return err
+	}
+	if err := set("Age", strconv.FormatUint(uint64(v.Age), 10)); err != nil {
+		return err
+	}
+	if err := set("Score", strconv.FormatFloat(v.Score, 'g', -1, 64)); err != nil {
+		return err
+	}
+	if err := set("Active", strconv.FormatBool(v.Active)); err != nil {
+		return err
+	}
+	if err := set("Level", strconv.FormatInt(int64(v.Level), 10)); err != nil {
+		return err
+	}
+	if err := set("Work City", v.Work.City); err != nil {
+		return err
+	}
+	if err := set("Work Floor", strconv.FormatInt(int64(v.Work.Floor), 10)); err != nil {
+		return err
+	}
+	if v.Home != nil {
+		if err := set("Home City", v.Home.City); err != nil {
+			return err
+		}
+		if err := set("Home Floor", strconv.FormatInt(int64(v.Home.Floor), 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalCSVForm implements form.RowUnmarshaler.
+func (v *Contact) UnmarshalCSVForm(get func(key string) (string, error)) error {
+	if s, err := get("Name"); err != nil {
+		return err
+	} else if s != "" {
+		v.Name = s
+	}
+	if s, err := get("Age"); err != nil {
+		return err
+	} else if s != "" {
+		n, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return &csv.RangeError{Column: "Age", Value: s, Type: reflect.TypeOf(v.Age)}
+			}
+			return errors.New("csv: Age +  Must be a a number")
+		}
+		v.Age = uint8(n)
+		if uint64(v.Age) < 18 {
+			return &csv.RangeError{Column: "Age", Value: s, Type: reflect.TypeOf(v.Age), Min: "18"}
+		}
+		if uint64(v.Age) > 120 {
+			return &csv.RangeError{Column: "Age", Value: s, Type: reflect.TypeOf(v.Age), Max: "120"}
+		}
+	}
+	if s, err := get("Score"); err != nil {
+		return err
+	} else if s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return &csv.RangeError{Column: "Score", Value: s, Type: reflect.TypeOf(v.Score)}
+			}
+			return errors.New("csv: Score +  Must be a a number")
+		}
+		v.Score = f
+	}
+	if s, err := get("Active"); err != nil {
+		return err
+	} else if s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("csv: Active +  Must be either true or false")
+		}
+		v.Active = b
+	}
+	if s, err := get("Level"); err != nil {
+		return err
+	} else if s != "" {
+		n, err := strconv.ParseInt(s, 10, 16)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return &csv.RangeError{Column: "Level", Value: s, Type: reflect.TypeOf(v.Level)}
+			}
+			return errors.New("csv: Level +  Must be a a number")
+		}
+		v.Level = int16(n)
+	}
+	if s, err := get("Work City"); err != nil {
+		return err
+	} else if s != "" {
+		v.Work.City = s
+	}
+	if s, err := get("Work Floor"); err != nil {
+		return err
+	} else if s != "" {
+		n, err := strconv.ParseInt(s, 10, strconv.IntSize)
+		if err != nil {
+			if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+				return &csv.RangeError{Column: "Work Floor", Value: s, Type: reflect.TypeOf(v.Work.Floor)}
+			}
+			return errors.New("csv: Floor +  Must be a a number")
+		}
+		v.Work.Floor = int(n)
+	}
+	{
+		p1 := v.Home
+		if p1 == nil {
+			p1 = new(Place)
+		}
+		filled2 := false
+		if s, err := get("Home City"); err != nil {
+			return err
+		} else if s != "" {
+			filled2 = true
+			p1.City = s
+		}
+		if s, err := get("Home Floor"); err != nil {
+			return err
+		} else if s != "" {
+			filled2 = true
+			n, err := strconv.ParseInt(s, 10, strconv.IntSize)
+			if err != nil {
+				if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+					return &csv.RangeError{Column: "Home Floor", Value: s, Type: reflect.TypeOf(p1.Floor)}
+				}
+				return errors.New("csv: Floor +  Must be a a number")
+			}
+			p1.Floor = int(n)
+		}
+		if filled2 || v.Home != nil {
+			v.Home = p1
+		}
+	}
+	return nil
+}
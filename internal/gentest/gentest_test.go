@@ -0,0 +1,167 @@
+package gentest
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xiphoid24/csv"
+	"github.com/xiphoid24/csv/form"
+)
+
+var (
+	_ csv.RowMarshaler    = Order{}
+	_ csv.RowUnmarshaler  = (*Order)(nil)
+	_ form.RowMarshaler   = Contact{}
+	_ form.RowUnmarshaler = (*Contact)(nil)
+)
+
+// plainOrder and plainContact have the fields of Order and Contact but none
+// of the generated methods, so they are encoded with reflection.
+type (
+	plainOrder   Order
+	plainContact Contact
+)
+
+func plainOrders(in []Order) []plainOrder {
+	out := make([]plainOrder, len(in))
+	for i, o := range in {
+		out[i] = plainOrder(o)
+	}
+	return out
+}
+
+func plainContacts(in []Contact) []plainContact {
+	out := make([]plainContact, len(in))
+	for i, c := range in {
+		out[i] = plainContact(c)
+	}
+	return out
+}
+
+var orders = []Order{
+	{
+		ID: 1, Ref: `say "hi", then leave`, Qty: 3, Mask: 0x7f, Price: 9.999, Ratio: 0.25, Paid: true,
+		Placed:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Note:    sql.NullString{String: "line\nbreak", Valid: true},
+		Count:   sql.NullInt64{Int64: math.MinInt64, Valid: true},
+		Shipped: sql.NullTime{Time: time.Date(2024, 3, 2, 15, 4, 5, 0, time.UTC), Valid: true},
+		Status:  "open",
+		Ship:    Address{City: "Zürich", Zip: "8001"},
+	},
+	{ID: -2, Qty: 500, Mask: -1, Price: -0.005, Ratio: 1, Placed: time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)},
+	{Qty: 1, Status: "closed"},
+}
+
+func TestOrderParity(t *testing.T) {
+	gen, err := csv.Marshal(orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refl, err := csv.Marshal(plainOrders(orders))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gen) != string(refl) {
+		t.Fatalf("Marshal differs\ngenerated:\n%s\nreflection:\n%s", gen, refl)
+	}
+
+	inputs := []string{
+		string(gen),
+		"Reference,id,qty\nby label,7,2\n",
+		"id,ref\n,\n5,x\n",
+		"qty\n0\n",
+		"qty\n501\n",
+		"qty\n70000\n",
+		"mask\nzz\n",
+		"mask\n80000000\n",
+		"ratio\n1.5\n",
+		"ratio\nNaN\n",
+		"price\nten\n",
+		"paid\ny\npaid\nmaybe\n",
+		"placed\n2024-13-01\n",
+		"shipped\nyesterday\n",
+		"count\n1.5\n",
+		"ship.city,ship.zip\nBern,3000\n",
+	}
+	for _, in := range inputs {
+		var a []Order
+		var b []plainOrder
+		errA := csv.Unmarshal([]byte(in), &a)
+		errB := csv.Unmarshal([]byte(in), &b)
+		if fmt.Sprint(errA) != fmt.Sprint(errB) {
+			t.Errorf("%q: Unmarshal error\ngenerated:  %v\nreflection: %v", in, errA, errB)
+			continue
+		}
+		if errA == nil && !reflect.DeepEqual(plainOrders(a), b) {
+			t.Errorf("%q: Unmarshal\ngenerated:  %+v\nreflection: %+v", in, a, b)
+		}
+	}
+
+	var back []Order
+	if err := csv.Unmarshal(gen, &back); err != nil {
+		t.Fatal(err)
+	}
+	for i := range back {
+		back[i].Price = orders[i].Price // written with two decimals
+	}
+	if !reflect.DeepEqual(back, orders) {
+		t.Errorf("Unmarshal(Marshal(orders)) =\n%+v\nwant\n%+v", back, orders)
+	}
+}
+
+var contacts = []Contact{
+	{Name: "Ann", Age: 30, Score: 1.5, Active: true, Level: -3, Work: Place{City: "Bern", Floor: 2}, Home: &Place{City: "Thun"}},
+	{Name: "Bob", Age: 120, Score: math.MaxFloat64, Level: math.MaxInt16},
+	{Name: `a, "b"`, Age: 18, Home: &Place{Floor: -1}},
+}
+
+func TestContactParity(t *testing.T) {
+	opts, err := form.GetOptions(Contact{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := map[string][]string{}
+	for i, opt := range opts {
+		rel[opt] = []string{fmt.Sprintf("col %d", i)}
+	}
+
+	gen, err := form.Marshal(contacts, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refl, err := form.Marshal(plainContacts(contacts), rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gen) != string(refl) {
+		t.Fatalf("Marshal differs\ngenerated:\n%s\nreflection:\n%s", gen, refl)
+	}
+
+	inputs := []string{
+		string(gen),
+		"col 0,col 1\nAnn,17\n",
+		"col 0,col 1\nAnn,121\n",
+		"col 0,col 1\nAnn,300\n",
+		"col 0,col 1\nAnn,old\n",
+		"col 0,col 2\nAnn,1e999\n",
+		"col 0,col 3\nAnn,maybe\n",
+		"col 0,col 4\nAnn,40000\n",
+	}
+	for _, in := range inputs {
+		var a []Contact
+		var b []plainContact
+		errA := form.Unmarshal([]byte(in), &a, rel)
+		errB := form.Unmarshal([]byte(in), &b, rel)
+		if fmt.Sprint(errA) != fmt.Sprint(errB) {
+			t.Errorf("%q: Unmarshal error\ngenerated:  %v\nreflection: %v", in, errA, errB)
+			continue
+		}
+		if errA == nil && !reflect.DeepEqual(plainContacts(a), b) {
+			t.Errorf("%q: Unmarshal\ngenerated:  %+v\nreflection: %+v", in, a, b)
+		}
+	}
+}
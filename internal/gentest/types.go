@@ -0,0 +1,56 @@
+// Package gentest holds types with methods generated by cmd/csvgen. The
+// generated file is the csvgen golden file, and the tests here check that
+// the methods encode and decode like reflection does.
+package gentest
+
+import (
+	"database/sql"
+	"time"
+)
+
+//go:generate go run ../../cmd/csvgen -type Order,Contact -o gen_csv.go
+
+// Order uses the csv tag options csvgen compiles in.
+type Order struct {
+	ID      int64          `csv:"id"`
+	Ref     string         `csv:"ref" csvlabel:"Reference"`
+	Qty     uint16         `csv:"qty,min=1,max=500"`
+	Mask    int32          `csv:"mask,base=16"`
+	Price   float64        `csv:"price,format=f,prec=2"`
+	Ratio   float32        `csv:"ratio,min=0,max=1"`
+	Paid    bool           `csv:"paid,true=Y,false=N"`
+	Placed  time.Time      `csv:"placed,layout=2006-01-02"`
+	Note    sql.NullString `csv:"note"`
+	Count   sql.NullInt64  `csv:"count"`
+	Shipped sql.NullTime   `csv:"shipped"`
+	Status  Status         `csv:"status"`
+	Ship    Address        `csv:"ship"`
+	seq     int            `csv:"-"`
+}
+
+// Address is nested in Order.
+type Address struct {
+	City string `csv:"city"`
+	Zip  string `csv:"zip"`
+}
+
+// Status is a named string type.
+type Status string
+
+// Contact uses csvform tags, with sub-objects reached directly and through
+// a pointer.
+type Contact struct {
+	Name   string  `csvform:"Name"`
+	Age    uint8   `csvform:"Age" csvmin:"18" csvmax:"120"`
+	Score  float64 `csvform:"Score"`
+	Active bool    `csvform:"Active"`
+	Level  int16   `csvform:"Level"`
+	Work   Place   `csvform:"Work"`
+	Home   *Place  `csvform:"Home"`
+}
+
+// Place is nested in Contact.
+type Place struct {
+	City  string `csvform:"City"`
+	Floor int    `csvform:"Floor"`
+}
@@ -0,0 +1,151 @@
+// Package randtype builds random struct types and values for the round-trip
+// tests of the csv and csv/form packages.
+package randtype
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+)
+
+// Kinds are the scalar field types both packages encode directly.
+var Kinds = []reflect.Type{
+	reflect.TypeOf(""),
+	reflect.TypeOf(int(0)),
+	reflect.TypeOf(int8(0)),
+	reflect.TypeOf(int16(0)),
+	reflect.TypeOf(int32(0)),
+	reflect.TypeOf(int64(0)),
+	reflect.TypeOf(uint(0)),
+	reflect.TypeOf(uint8(0)),
+	reflect.TypeOf(uint16(0)),
+	reflect.TypeOf(uint32(0)),
+	reflect.TypeOf(uint64(0)),
+	reflect.TypeOf(float32(0)),
+	reflect.TypeOf(float64(0)),
+	reflect.TypeOf(false),
+}
+
+// Strings are awkward values that have to survive quoting. "\r\n" is left
+// out: encoding/csv reads it back as "\n" inside a quoted field.
+var Strings = []string{
+	"", " ", "plain", "a,b", `say "hi"`, `"`, `""`, "line\nbreak", "cr\rreturn",
+	" leading", "trailing ", "tab\tbed", "ünïcödé", "日本語", `\.`, "#comment",
+}
+
+// Gen generates struct types with the tags of one package.
+type Gen struct {
+	// Tag returns the struct tag of field i, which may be empty.
+	Tag func(r *rand.Rand, i int) reflect.StructTag
+	// Skip reports fields that are never written, which Value leaves at
+	// their zero value.
+	Skip func(f reflect.StructField) bool
+	// Pointers allows nested structs to be reached through a pointer.
+	Pointers bool
+}
+
+// Type builds a random struct type. Nested structs are generated up to
+// depth levels deep.
+func (g Gen) Type(r *rand.Rand, depth int) reflect.Type {
+	n := 1 + r.Intn(5)
+	fields := make([]reflect.StructField, 0, n)
+	for i := 0; i < n; i++ {
+		f := reflect.StructField{Name: fmt.Sprintf("F%d", i), Tag: g.Tag(r, i)}
+		switch {
+		case depth > 0 && r.Intn(4) == 0:
+			f.Type = g.Type(r, depth-1)
+		case depth > 0 && g.Pointers && r.Intn(4) == 0:
+			f.Type = reflect.PtrTo(g.Type(r, depth-1))
+		default:
+			f.Type = Kinds[r.Intn(len(Kinds))]
+		}
+		fields = append(fields, f)
+	}
+	return reflect.StructOf(fields)
+}
+
+// Value fills a value of a type made by Type. Some pointers are left nil.
+func (g Gen) Value(r *rand.Rand, t reflect.Type) reflect.Value {
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if g.Skip(t.Field(i)) {
+				continue
+			}
+			v.Field(i).Set(g.Value(r, t.Field(i).Type))
+		}
+	case reflect.Ptr:
+		if r.Intn(3) > 0 {
+			p := reflect.New(t.Elem())
+			p.Elem().Set(g.Value(r, t.Elem()))
+			v.Set(p)
+		}
+	case reflect.String:
+		v.SetString(Strings[r.Intn(len(Strings))])
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(r.Int63() >> (64 - uint(t.Bits())))
+		if r.Intn(2) == 0 {
+			v.SetInt(-v.Int() - 1)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(r.Uint64() >> (64 - uint(t.Bits())))
+	case reflect.Float32:
+		v.SetFloat(float64(r.Float32()*math.MaxFloat32) * float64(r.Intn(3)-1))
+	case reflect.Float64:
+		v.SetFloat(r.NormFloat64() * math.Pow(10, float64(r.Intn(40)-20)))
+	case reflect.Bool:
+		v.SetBool(r.Intn(2) == 0)
+	}
+	return v
+}
+
+// Slice makes a slice of 1 to 8 values of t. Strings are made empty more
+// often than Value would, so that some elements encode to empty rows.
+func (g Gen) Slice(r *rand.Rand, t reflect.Type) reflect.Value {
+	n := 1 + r.Intn(8)
+	s := reflect.MakeSlice(reflect.SliceOf(t), 0, n)
+	for i := 0; i < n; i++ {
+		v := g.Value(r, t)
+		if r.Intn(4) == 0 {
+			g.blank(v)
+		}
+		s = reflect.Append(s, v)
+	}
+	return s
+}
+
+// blank clears the strings of v and its pointers.
+func (g Gen) blank(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			g.blank(v.Field(i))
+		}
+	case reflect.Ptr:
+		v.Set(reflect.Zero(v.Type()))
+	case reflect.String:
+		v.SetString("")
+	}
+}
+
+// Empty reports whether v writes no value but empty strings: every field
+// that is not skipped is an empty string, a nil pointer or a struct that is
+// itself empty.
+func (g Gen) Empty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !g.Skip(v.Type().Field(i)) && !g.Empty(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr:
+		return v.IsNil() || g.Empty(v.Elem())
+	case reflect.String:
+		return v.Len() == 0
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package shared
+
+import "strings"
+
+// Spreadsheets run cells starting with these characters as formulas, so
+// strings from users can smuggle formulas into an export. Following the
+// OWASP CSV injection guidance such cells are escaped with a leading single
+// quote, which spreadsheets display as text.
+const formulaChars = "=+-@\t\r"
+
+// isFormula reports whether s needs escaping. Strings that already start
+// with a quote followed by a formula are escaped too, so that unescaping
+// gives back exactly what was escaped.
+func isFormula(s string) bool {
+	for len(s) > 0 && s[0] == '\'' {
+		s = s[1:]
+	}
+	return len(s) > 0 && strings.IndexByte(formulaChars, s[0]) >= 0
+}
+
+// EscapeFormula prefixes s with a single quote when a spreadsheet would run
+// it as a formula.
+func EscapeFormula(s string) string {
+	if isFormula(s) {
+		return "'" + s
+	}
+	return s
+}
+
+// UnescapeFormula removes the quote EscapeFormula adds.
+func UnescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && isFormula(s[1:]) {
+		return s[1:]
+	}
+	return s
+}
@@ -0,0 +1,24 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/csv"
+)
+
+// JoinRow renders one record without a line ending, with fields separated
+// by comma, or by a comma when it is 0. Cells that contain the separator, a
+// quote or a line break are quoted so the output reads back unchanged.
+func JoinRow(cells []string, comma rune) []byte {
+	// a lone empty cell would be written as a blank line, which readers skip
+	if len(cells) == 1 && cells[0] == "" {
+		return []byte(`""`)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if comma != 0 {
+		w.Comma = comma
+	}
+	w.Write(cells)
+	w.Flush()
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
@@ -0,0 +1,22 @@
+package shared
+
+import "testing"
+
+func TestJoinRow(t *testing.T) {
+	tests := []struct {
+		cells []string
+		comma rune
+		want  string
+	}{
+		{[]string{"a", "b"}, 0, "a,b"},
+		{[]string{"a,b", `say "hi"`, "x\ny"}, 0, "\"a,b\",\"say \"\"hi\"\"\",\"x\ny\""},
+		{[]string{""}, 0, `""`},
+		{[]string{"", ""}, 0, ","},
+		{[]string{"a,b", "c;d"}, ';', "a,b;\"c;d\""},
+	}
+	for _, tt := range tests {
+		if got := string(JoinRow(tt.cells, tt.comma)); got != tt.want {
+			t.Errorf("JoinRow(%q) = %q, want %q", tt.cells, got, tt.want)
+		}
+	}
+}
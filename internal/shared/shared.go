@@ -0,0 +1,272 @@
+// Package shared holds the row policies, limits, validation and error
+// types that the csv and csv/form decoders have in common, which both
+// packages re-export under their own names, and the record writing and
+// formula escaping that every encoder and decoder in the module applies.
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// EmptyRowPolicy controls what Decode does with a row that leaves every
+// field empty.
+type EmptyRowPolicy int
+
+const (
+	// SkipEmptyRows drops empty rows. It is the default.
+	SkipEmptyRows EmptyRowPolicy = iota
+	// KeepEmptyRows appends a zero value for each empty row, so the output
+	// stays aligned with the records of the input. Blank lines are not
+	// records; RowError.Line gives the line of a record.
+	KeepEmptyRows
+	// ErrorEmptyRows stops decoding with a *RowError wrapping EMPTYROW.
+	ErrorEmptyRows
+)
+
+var EmptyRow = errors.New("CSV EMPTY ROW")
+
+// RowError reports a problem with a single data row. Row is the index of the
+// record in the input, with the header at row 0. Line is the line of the
+// input the record starts on, counting from 1, when it is known; it differs
+// from Row when the input has blank lines, which are skipped, or quoted
+// fields spanning several lines.
+type RowError struct {
+	Row  int
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("csv: row %d (line %d): %v", e.Row, e.Line, e.Err)
+	}
+	return fmt.Sprintf("csv: row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// AtLine fills in the Line of err, when it is a *RowError without one,
+// from lines, the start line of each record.
+func AtLine(err error, lines []int) error {
+	var re *RowError
+	if errors.As(err, &re) && re.Line == 0 && re.Row >= 0 && re.Row < len(lines) {
+		re.Line = lines[re.Row]
+	}
+	return err
+}
+
+// RangeError reports a number that does not fit its field, either because
+// it overflows the field's type or because it is outside the field's min or
+// max tag option. Min or Max is set to the violated bound in the latter case.
+type RangeError struct {
+	Column string
+	Value  string
+	Type   reflect.Type
+	Min    string
+	Max    string
+}
+
+func (e *RangeError) Error() string {
+	switch {
+	case e.Min != "":
+		return fmt.Sprintf("csv: %s: %q is below the minimum of %s", e.Column, e.Value, e.Min)
+	case e.Max != "":
+		return fmt.Sprintf("csv: %s: %q is above the maximum of %s", e.Column, e.Value, e.Max)
+	}
+	return fmt.Sprintf("csv: %s: %q is out of range for %s", e.Column, e.Value, e.Type)
+}
+
+// IsRangeErr reports whether err is a strconv error for a number that does
+// not fit its type.
+func IsRangeErr(err error) bool {
+	ne, ok := err.(*strconv.NumError)
+	return ok && ne.Err == strconv.ErrRange
+}
+
+// CheckBounds enforces min and max, the bounds set on a numeric field, on
+// its decoded value. Empty bounds are not checked, and NaN is outside any
+// bound. Column is left for the caller to fill in.
+func CheckBounds(fld reflect.Value, s, min, max string) error {
+	for _, b := range []struct{ key, bound string }{{"min", min}, {"max", max}} {
+		if b.bound == "" {
+			continue
+		}
+		var cmp int
+		switch fld.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(b.bound, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s option %q", b.key, b.bound)
+			}
+			cmp = compareInt(fld.Int(), n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(b.bound, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s option %q", b.key, b.bound)
+			}
+			cmp = compareUint(fld.Uint(), n)
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(b.bound, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s option %q", b.key, b.bound)
+			}
+			cmp = compareFloat(fld.Float(), f)
+			if math.IsNaN(fld.Float()) {
+				cmp = -1
+				if b.key == "max" {
+					cmp = 1
+				}
+			}
+		default:
+			continue
+		}
+		if b.key == "min" && cmp < 0 {
+			return &RangeError{Value: s, Type: fld.Type(), Min: b.bound}
+		}
+		if b.key == "max" && cmp > 0 {
+			return &RangeError{Value: s, Type: fld.Type(), Max: b.bound}
+		}
+	}
+	return nil
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Validator is implemented by types that check their own business rules.
+// Decode calls Validate on every decoded element and reports a failure as a
+// *RowError.
+type Validator interface {
+	Validate() error
+}
+
+// Validate runs v's own Validate method, if it has one, followed by hook,
+// if set. v is a pointer to the decoded element.
+func Validate(rowNum int, v interface{}, hook func(row int, v interface{}) error) error {
+	if vd, ok := v.(Validator); ok {
+		if err := vd.Validate(); err != nil {
+			return &RowError{Row: rowNum, Err: err}
+		}
+	}
+	if hook != nil {
+		if err := hook(rowNum, v); err != nil {
+			return &RowError{Row: rowNum, Err: err}
+		}
+	}
+	return nil
+}
+
+// Limits bounds the input a decoder accepts, for reading untrusted
+// uploads. Zero fields are unlimited.
+type Limits struct {
+	// MaxRows is the largest number of records, the header included.
+	MaxRows int
+	// MaxColumns is the largest number of fields in a record.
+	MaxColumns int
+	// MaxFieldBytes is the largest size of a single field.
+	MaxFieldBytes int
+	// MaxBytes is the largest size of the whole input, once decompressed.
+	MaxBytes int64
+}
+
+// LimitError reports input over one of a decoder's Limits. Limit names the
+// Limits field and Row the record at fault, with the header at row 0. Row
+// is not set when MaxBytes is exceeded.
+type LimitError struct {
+	Limit string
+	Max   int64
+	Row   int
+}
+
+func (e *LimitError) Error() string {
+	if e.Limit == "MaxBytes" {
+		return fmt.Sprintf("csv: input exceeds MaxBytes of %d", e.Max)
+	}
+	return fmt.Sprintf("csv: row %d exceeds %s of %d", e.Row, e.Limit, e.Max)
+}
+
+// CheckBytes checks n, the number of bytes of input read so far, against
+// MaxBytes.
+func CheckBytes(l Limits, n int64) error {
+	if l.MaxBytes > 0 && n > l.MaxBytes {
+		return &LimitError{Limit: "MaxBytes", Max: l.MaxBytes}
+	}
+	return nil
+}
+
+// LimitReader returns a reader of r that fails with a *LimitError once more
+// than l.MaxBytes bytes are read, or r itself when MaxBytes is not set.
+func LimitReader(r io.Reader, l Limits) io.Reader {
+	if l.MaxBytes <= 0 {
+		return r
+	}
+	return &limitReader{r: r, limits: l}
+}
+
+type limitReader struct {
+	r      io.Reader
+	n      int64
+	limits Limits
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if err := CheckBytes(l.limits, l.n); err != nil {
+		return 0, err
+	}
+	return n, err
+}
+
+// CheckRow checks a record as it is read, before it is kept. rowNum is the
+// index the record will have.
+func CheckRow(l Limits, rowNum int, row []string) error {
+	if l.MaxRows > 0 && rowNum >= l.MaxRows {
+		return &LimitError{Limit: "MaxRows", Max: int64(l.MaxRows), Row: rowNum}
+	}
+	if l.MaxColumns > 0 && len(row) > l.MaxColumns {
+		return &LimitError{Limit: "MaxColumns", Max: int64(l.MaxColumns), Row: rowNum}
+	}
+	if l.MaxFieldBytes > 0 {
+		for _, field := range row {
+			if len(field) > l.MaxFieldBytes {
+				return &LimitError{Limit: "MaxFieldBytes", Max: int64(l.MaxFieldBytes), Row: rowNum}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package tags parses csv struct tags, `csv:"name,option=value,..."`, for
+// the csv package and the csvgen generator, so both read a tag the same
+// way.
+package tags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Options holds the comma separated options that follow the column name
+// in a csv struct tag, e.g. `csv:"price,format=f,prec=2"`. Options without a
+// value are stored with an empty value.
+//
+// The column name or an option value that contains a comma is wrapped in
+// single quotes, with a quote inside doubled, e.g.
+// `csv:"'Last, First',layout='Jan 2, 2006'"`.
+type Options map[string]string
+
+// Parse splits a csv struct tag into the column name and its options.
+func Parse(tag string) (string, Options) {
+	parts := split(tag)
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	opts := Options{}
+	for _, opt := range parts[1:] {
+		key, val := opt, ""
+		if i := strings.Index(opt, "="); i >= 0 {
+			key, val = opt[:i], opt[i+1:]
+		}
+		opts[strings.TrimSpace(key)] = val
+	}
+	return parts[0], opts
+}
+
+// split splits tag at the commas that are not inside single quotes,
+// unquoting the column name and option values. A tag with an unclosed
+// quote is split at every comma.
+func split(tag string) []string {
+	var parts []string
+	var b strings.Builder
+	// start is set where a quoted name or value may begin
+	quoted, start, eq := false, true, false
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		if quoted {
+			if c == '\'' && i+1 < len(tag) && tag[i+1] == '\'' {
+				i++
+			} else if c == '\'' {
+				quoted = false
+				continue
+			}
+			b.WriteByte(c)
+			continue
+		}
+		switch {
+		case c == '\'' && start:
+			quoted, start = true, false
+			continue
+		case c == ',':
+			parts = append(parts, b.String())
+			b.Reset()
+			start, eq = true, false
+			continue
+		}
+		b.WriteByte(c)
+		start = c == '=' && !eq && len(parts) > 0
+		eq = eq || c == '='
+	}
+	if quoted {
+		return strings.Split(tag, ",")
+	}
+	return append(parts, b.String())
+}
+
+// Quote quotes a column name or option value for a csv struct tag when it
+// needs it.
+func Quote(s string) string {
+	if !strings.Contains(s, ",") && !strings.HasPrefix(s, "'") {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func (o Options) Has(key string) bool {
+	_, ok := o[key]
+	return ok
+}
+
+func (o Options) Get(key string) (string, bool) {
+	v, ok := o[key]
+	return v, ok
+}
+
+// Int returns the integer value of an option, or def when it is not set.
+func (o Options) Int(key string, def int) (int, error) {
+	v, ok := o[key]
+	if !ok {
+		return def, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s option %q", key, v)
+	}
+	return i, nil
+}
+
+// Base returns the integer base set by the base option, or def when it is
+// not set. Bases outside 2 to 36 are errors.
+func (o Options) Base(def int) (int, error) {
+	v, ok := o["base"]
+	if !ok {
+		if def < 2 || def > 36 {
+			return 0, fmt.Errorf("invalid integer base %d", def)
+		}
+		return def, nil
+	}
+	base, err := strconv.Atoi(v)
+	if err != nil || base < 2 || base > 36 {
+		return 0, fmt.Errorf("invalid base option %q", v)
+	}
+	return base, nil
+}
@@ -0,0 +1,51 @@
+package tags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		tag  string
+		name string
+		opts Options
+	}{
+		{"price", "price", nil},
+		{"price,format=f,prec=2", "price", Options{"format": "f", "prec": "2"}},
+		{"flag,true=Y,false=N,omit", "flag", Options{"true": "Y", "false": "N", "omit": ""}},
+		{"'Last, First'", "Last, First", nil},
+		{"when,layout='Jan 2, 2006'", "when", Options{"layout": "Jan 2, 2006"}},
+		{"'it''s',true='a,b'", "it's", Options{"true": "a,b"}},
+		{"it's,true=x'y", "it's", Options{"true": "x'y"}},
+		{"a,b=c=d", "a", Options{"b": "c=d"}},
+		{"'open,x=1", "'open", Options{"x": "1"}},
+	}
+	for _, tt := range tests {
+		name, opts := Parse(tt.tag)
+		if name != tt.name || !reflect.DeepEqual(opts, tt.opts) {
+			t.Errorf("Parse(%q) = %q, %v, want %q, %v", tt.tag, name, opts, tt.name, tt.opts)
+		}
+	}
+	for _, s := range []string{"plain", "a,b", "'q'", "x'y,z"} {
+		if _, opts := Parse("c,v=" + Quote(s)); opts["v"] != s {
+			t.Errorf("Quote(%q) reads back as %q", s, opts["v"])
+		}
+	}
+}
+
+func TestOptionErrors(t *testing.T) {
+	opts := Options{"base": "x", "prec": "y"}
+	if _, err := opts.Base(10); err == nil || err.Error() != `invalid base option "x"` {
+		t.Errorf("Base: error = %v", err)
+	}
+	if _, err := opts.Int("prec", 0); err == nil || err.Error() != `invalid prec option "y"` {
+		t.Errorf("Int: error = %v", err)
+	}
+	if _, err := Options(nil).Base(40); err == nil || err.Error() != "invalid integer base 40" {
+		t.Errorf("Base(40): error = %v", err)
+	}
+	if n, err := Options(nil).Int("prec", 3); n != 3 || err != nil {
+		t.Errorf("Int default = %d, %v", n, err)
+	}
+}
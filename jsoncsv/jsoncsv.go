@@ -0,0 +1,354 @@
+// Package jsoncsv converts CSV data to JSON arrays or newline-delimited JSON
+// and back.
+//
+// The untyped functions work on any file. Dotted headers such as
+// "ship.street", which the csv package writes for nested structs, become
+// nested objects, and every value is a JSON string. Going back, nested
+// objects are flattened into dotted headers.
+//
+// The typed functions convert through a Go struct: CSV is decoded with
+// csv.Unmarshal and encoded with encoding/json, so numbers and bools keep
+// their JSON types and json tags name the keys.
+package jsoncsv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/xiphoid24/csv"
+	"github.com/xiphoid24/csv/internal/shared"
+)
+
+// node is one key of the objects built from a header. Leaves hold the
+// index of their column, objects their children in header order.
+type node struct {
+	name     string
+	col      int
+	children []*node
+}
+
+// headerTree nests the dotted header columns cols into objects.
+func headerTree(header []string, cols []int) (*node, error) {
+	root := &node{col: -1}
+	for _, i := range cols {
+		h := header[i]
+		n := root
+		parts := strings.Split(h, ".")
+		for depth, part := range parts {
+			var child *node
+			for _, c := range n.children {
+				if c.name == part {
+					child = c
+					break
+				}
+			}
+			leaf := depth == len(parts)-1
+			switch {
+			case child == nil:
+				child = &node{name: part, col: -1}
+				n.children = append(n.children, child)
+			case leaf || child.col >= 0:
+				return nil, fmt.Errorf("jsoncsv: column %q conflicts with another column", h)
+			}
+			if leaf {
+				child.col = i
+			}
+			n = child
+		}
+	}
+	return root, nil
+}
+
+// write writes the object of a row whose cells are strings, or nil for
+// nulls.
+func (n *node) write(buf *bytes.Buffer, cells []interface{}) {
+	if n.col >= 0 {
+		var cell interface{} = ""
+		if n.col < len(cells) {
+			cell = cells[n.col]
+		}
+		b, _ := json.Marshal(cell)
+		buf.Write(b)
+		return
+	}
+	buf.WriteByte('{')
+	for i, c := range n.children {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		b, _ := json.Marshal(c.name)
+		buf.Write(b)
+		buf.WriteByte(':')
+		c.write(buf, cells)
+	}
+	buf.WriteByte('}')
+}
+
+// convert writes one JSON object per data row of b, separated by sep. The
+// decoder options apply as in Unmarshal: rows without any value follow the
+// EmptyRows policy, csv.DecodeColumns keeps only the named columns,
+// csv.ParseNulls writes null for its tokens and csv.UnescapeFormulas
+// unescapes every cell. csv.ParseLabels has no struct paths to label and
+// is rejected; options that convert typed fields do not apply.
+func convert(b []byte, open, sep, close string, opts []csv.DecoderOption) ([]byte, error) {
+	dec, err := csv.NewCSVDecoder(b, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if dec.Labeler != nil {
+		return nil, fmt.Errorf("jsoncsv: header labels do not apply to untyped conversion")
+	}
+	cols, err := columns(dec)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := headerTree(dec.Rows[0], cols)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(open)
+	n := 0
+	cells := make([]interface{}, len(dec.Rows[0]))
+	for i, row := range dec.Rows[1:] {
+		filled := false
+		for _, col := range cols {
+			cells[col] = value(dec, row, col)
+			filled = filled || (cells[col] != nil && cells[col] != "")
+		}
+		if !filled {
+			switch dec.EmptyRows {
+			case csv.SkipEmptyRows:
+				continue
+			case csv.ErrorEmptyRows:
+				return nil, &csv.RowError{Row: i + 1, Line: dec.Line(i + 1), Err: csv.EMPTYROW}
+			}
+		}
+		if n > 0 {
+			buf.WriteString(sep)
+		}
+		tree.write(&buf, cells)
+		n++
+	}
+	buf.WriteString(close)
+	return buf.Bytes(), nil
+}
+
+// columns returns the index of each header column to convert: the ones
+// csv.DecodeColumns names, or all of them.
+func columns(dec *csv.CSVDecoder) ([]int, error) {
+	header := dec.Rows[0]
+	if len(dec.Select) == 0 {
+		cols := make([]int, len(header))
+		for i := range cols {
+			cols[i] = i
+		}
+		return cols, nil
+	}
+	cols := make([]int, 0, len(dec.Select))
+	for _, path := range dec.Select {
+		i, ok := dec.HeaderMap[path]
+		if !ok {
+			return nil, fmt.Errorf("jsoncsv: unknown column %q", path)
+		}
+		cols = append(cols, i)
+	}
+	sort.Ints(cols)
+	return cols, nil
+}
+
+// value returns cell col of row as a string, or nil when it is one of the
+// decoder's null tokens.
+func value(dec *csv.CSVDecoder, row []string, col int) interface{} {
+	if col >= len(row) {
+		return ""
+	}
+	cell := row[col]
+	for _, tok := range dec.NullTokens {
+		if cell == tok {
+			return nil
+		}
+	}
+	if dec.UnescapeFormulas {
+		cell = shared.UnescapeFormula(cell)
+	}
+	return cell
+}
+
+// ToJSON converts CSV data to a JSON array with one object per row. Rows
+// without any value are skipped unless csv.WithEmptyRows says otherwise.
+func ToJSON(b []byte, opts ...csv.DecoderOption) ([]byte, error) {
+	return convert(b, "[", ",", "]", opts)
+}
+
+// ToNDJSON converts CSV data to newline-delimited JSON, one object per line.
+func ToNDJSON(b []byte, opts ...csv.DecoderOption) ([]byte, error) {
+	out, err := convert(b, "", "\n", "", opts)
+	if err != nil || len(out) == 0 {
+		return out, err
+	}
+	return append(out, '\n'), nil
+}
+
+// FromJSON converts a JSON array of objects to CSV. The header holds every
+// key found, in order of first appearance. Strings are written as they
+// are, null and empty objects as an empty cell, and arrays as their JSON
+// text. An empty array gives empty output.
+func FromJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('[') {
+		return nil, fmt.Errorf("jsoncsv: expected a JSON array")
+	}
+	var t table
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if err := t.add(raw); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return t.csv()
+}
+
+// FromNDJSON converts newline-delimited JSON objects to CSV, like FromJSON.
+func FromNDJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	var t table
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := t.add(raw); err != nil {
+			return nil, err
+		}
+	}
+	return t.csv()
+}
+
+// table collects flattened records and the union of their columns.
+// objects holds the columns of empty objects.
+type table struct {
+	header  []string
+	columns map[string]int
+	objects map[string]bool
+	records []map[string]string
+}
+
+func (t *table) add(raw json.RawMessage) error {
+	if t.columns == nil {
+		t.columns, t.objects = map[string]int{}, map[string]bool{}
+	}
+	rec := map[string]string{}
+	if err := t.flatten(raw, "", rec); err != nil {
+		return fmt.Errorf("jsoncsv: record %d: %v", len(t.records)+1, err)
+	}
+	t.records = append(t.records, rec)
+	return nil
+}
+
+// flatten adds the keys of the JSON object raw to rec, prefixed with start.
+func (t *table) flatten(raw json.RawMessage, start string, rec map[string]string) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return fmt.Errorf("not a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := start + tok.(string)
+
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return err
+		}
+		switch val[0] {
+		case '{':
+			n := len(rec)
+			if err := t.flatten(val, key+".", rec); err != nil {
+				return err
+			}
+			if len(rec) > n {
+				continue
+			}
+			// an empty object keeps its column, unless other records
+			// give it keys
+			rec[key] = ""
+			t.objects[key] = true
+		case '"':
+			var s string
+			if err := json.Unmarshal(val, &s); err != nil {
+				return err
+			}
+			rec[key] = s
+		case 'n':
+			rec[key] = ""
+		default:
+			var buf bytes.Buffer
+			if err := json.Compact(&buf, val); err != nil {
+				return err
+			}
+			rec[key] = buf.String()
+		}
+		if _, ok := t.columns[key]; !ok {
+			t.columns[key] = len(t.header)
+			t.header = append(t.header, key)
+		}
+	}
+	return nil
+}
+
+func (t *table) csv() ([]byte, error) {
+	if len(t.records) == 0 {
+		return []byte{}, nil
+	}
+	header := make([]string, 0, len(t.header))
+	for _, key := range t.header {
+		if !t.objects[key] || !t.nested(key) {
+			header = append(header, key)
+		}
+	}
+	if len(header) == 0 {
+		return nil, fmt.Errorf("jsoncsv: no keys to write")
+	}
+	rows := [][]byte{shared.JoinRow(header, 0)}
+	row := make([]string, len(header))
+	for _, rec := range t.records {
+		for i, key := range header {
+			row[i] = rec[key]
+		}
+		rows = append(rows, shared.JoinRow(row, 0))
+	}
+	return bytes.Join(rows, []byte("\n")), nil
+}
+
+// nested reports whether some column lies inside the object key.
+func (t *table) nested(key string) bool {
+	for _, h := range t.header {
+		if strings.HasPrefix(h, key+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package jsoncsv
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/xiphoid24/csv"
+)
+
+func TestToJSON(t *testing.T) {
+	in := "id,ship.street,ship.city\n1,\"Main St, 1\",Bern\n,,\n2,,\n"
+	tests := []struct {
+		opts []csv.DecoderOption
+		want string
+	}{
+		{nil, `[{"id":"1","ship":{"street":"Main St, 1","city":"Bern"}},{"id":"2","ship":{"street":"","city":""}}]`},
+		{
+			[]csv.DecoderOption{csv.WithEmptyRows(csv.KeepEmptyRows)},
+			`[{"id":"1","ship":{"street":"Main St, 1","city":"Bern"}},{"id":"","ship":{"street":"","city":""}},{"id":"2","ship":{"street":"","city":""}}]`,
+		},
+		{
+			[]csv.DecoderOption{csv.DecodeColumns("ship.city", "id")},
+			`[{"id":"1","ship":{"city":"Bern"}},{"id":"2","ship":{"city":""}}]`,
+		},
+		{
+			[]csv.DecoderOption{csv.DecodeColumns("ship.street")},
+			`[{"ship":{"street":"Main St, 1"}}]`,
+		},
+	}
+	for _, tt := range tests {
+		got, err := ToJSON([]byte(in), tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("ToJSON =\n%s\nwant\n%s", got, tt.want)
+		}
+	}
+
+	_, err := ToJSON([]byte(in), csv.WithEmptyRows(csv.ErrorEmptyRows))
+	var re *csv.RowError
+	if !errors.As(err, &re) || re.Row != 2 || re.Line != 3 || !errors.Is(err, csv.EMPTYROW) {
+		t.Errorf("ToJSON with ErrorEmptyRows: error = %v, want an empty row error for row 2, line 3", err)
+	}
+
+	got, err := ToJSON([]byte("a,b\n'=1,NULL\nNULL,\n"), csv.ParseNulls("NULL"), csv.UnescapeFormulas())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"a":"=1","b":null}]`; string(got) != want {
+		t.Errorf("ToJSON with nulls and formulas = %s, want %s", got, want)
+	}
+	if _, err := ToJSON([]byte(in), csv.DecodeColumns("ship")); err == nil {
+		t.Error("ToJSON with an unknown column: no error")
+	}
+	if _, err := ToJSON([]byte(in), csv.ParseLabels(func(string) string { return "" })); err == nil {
+		t.Error("ToJSON with ParseLabels: no error")
+	}
+
+	got, err = ToNDJSON([]byte("a\n\"\"\nx\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"a\":\"x\"}\n"; string(got) != want {
+		t.Errorf("ToNDJSON = %q, want %q", got, want)
+	}
+
+	if _, err := ToJSON([]byte("a.b,a\n1,2\n")); err == nil {
+		t.Error("ToJSON with conflicting columns: no error")
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`[]`, ``},
+		{` [ ] `, ``},
+		{`[{"a":"x"},{"a":""},{"a":null}]`, "a\nx\n\"\"\n\"\""},
+		{`[{"a":"1","meta":{}},{"a":"2"}]`, "a,meta\n1,\n2,"},
+		{`[{"meta":{}},{"meta":{"k":"v"}}]`, "meta.k\n\"\"\nv"},
+		{`[{"n":1.50,"b":true,"l":[1, 2],"o":{"p":{"q":"r"}}}]`, "n,b,l,o.p.q\n1.50,true,\"[1,2]\",r"},
+		{`[{"a":"1"},{"b":"2"}]`, "a,b\n1,\n,2"},
+	}
+	for _, tt := range tests {
+		got, err := FromJSON([]byte(tt.in))
+		if err != nil {
+			t.Errorf("FromJSON(%s): %v", tt.in, err)
+			continue
+		}
+		if got == nil || string(got) != tt.want {
+			t.Errorf("FromJSON(%s) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{`{"a":1}`, `[1]`, `[{}]`, `[{"a":1}`} {
+		if _, err := FromJSON([]byte(in)); err == nil {
+			t.Errorf("FromJSON(%s): no error", in)
+		}
+	}
+
+	got, err := FromNDJSON([]byte(""))
+	if err != nil || got == nil || len(got) != 0 {
+		t.Errorf("FromNDJSON of no records = %q, %v, want empty output", got, err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"a\n\"\"\nx",
+		"id,ship.street,ship.city\n1,\"Main St, 1\",Bern\n2,\"say \"\"hi\"\"\",\"line\nbreak\"",
+		"a,b.c,b.d.e\n,,x\ny,,",
+	}
+	for _, in := range inputs {
+		j, err := ToJSON([]byte(in), csv.WithEmptyRows(csv.KeepEmptyRows))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := FromJSON(j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != in {
+			t.Errorf("FromJSON(ToJSON(%q)) = %q\n%s", in, out, j)
+		}
+
+		nd, err := ToNDJSON([]byte(in), csv.WithEmptyRows(csv.KeepEmptyRows))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out, err = FromNDJSON(nd); err != nil || string(out) != in {
+			t.Errorf("FromNDJSON(ToNDJSON(%q)) = %q, %v\n%s", in, out, err, nd)
+		}
+	}
+}
+
+type item struct {
+	SKU   string  `csv:"sku" json:"sku"`
+	Qty   int     `csv:"qty" json:"qty"`
+	Price float64 `csv:"price" json:"price"`
+	Gift  bool    `csv:"gift" json:"gift"`
+	Ship  struct {
+		City string `csv:"city" json:"city"`
+	} `csv:"ship" json:"ship"`
+}
+
+func TestRoundTripTyped(t *testing.T) {
+	in := `[{"sku":"a,1","qty":2,"price":9.5,"gift":true,"ship":{"city":"Bern"}},{"sku":"b","qty":-1,"price":0,"gift":false,"ship":{"city":""}}]`
+
+	var items []item
+	b, err := FromJSONAs([]byte(in), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "sku,qty,price,gift,ship.city\n\"a,1\",2,9.5,true,Bern\nb,-1,0,false,"; string(b) != want {
+		t.Errorf("FromJSONAs = %q, want %q", b, want)
+	}
+
+	var back []item
+	out, err := ToJSONAs(b, &back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("ToJSONAs =\n%s\nwant\n%s", out, in)
+	}
+
+	var nd []item
+	lines, err := ToNDJSONAs(b, &nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again []item
+	b2, err := FromNDJSONAs(lines, &again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b2) != string(b) || !reflect.DeepEqual(again, items) {
+		t.Errorf("FromNDJSONAs(ToNDJSONAs) = %q, want %q", b2, b)
+	}
+
+	var v []item
+	if _, err := ToJSONAs(b, v); err == nil {
+		t.Error("ToJSONAs into a slice: no error")
+	}
+	if err := json.Unmarshal(out, &v); err != nil || !reflect.DeepEqual(v, items) {
+		t.Errorf("ToJSONAs output reads back as %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,88 @@
+package jsoncsv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/xiphoid24/csv"
+)
+
+// sliceOf checks that v is a pointer to a slice and returns the slice.
+func sliceOf(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("jsoncsv: expected a pointer to a slice, got %T", v)
+	}
+	return rv.Elem(), nil
+}
+
+// ToJSONAs decodes b into v, a pointer to a slice of structs, and returns
+// v as a JSON array.
+func ToJSONAs(b []byte, v interface{}, opts ...csv.DecoderOption) ([]byte, error) {
+	if _, err := sliceOf(v); err != nil {
+		return nil, err
+	}
+	if err := csv.Unmarshal(b, v, opts...); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// ToNDJSONAs decodes b into v, a pointer to a slice of structs, and returns
+// its elements as newline-delimited JSON.
+func ToNDJSONAs(b []byte, v interface{}, opts ...csv.DecoderOption) ([]byte, error) {
+	slice, err := sliceOf(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := csv.Unmarshal(b, v, opts...); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := 0; i < slice.Len(); i++ {
+		if err := enc.Encode(slice.Index(i).Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// FromJSONAs decodes the JSON array b into v, a pointer to a slice of
+// structs, and returns v as CSV.
+func FromJSONAs(b []byte, v interface{}, opts ...csv.EncoderOption) ([]byte, error) {
+	slice, err := sliceOf(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	return csv.Marshal(slice.Interface(), opts...)
+}
+
+// FromNDJSONAs decodes the newline-delimited JSON b into v, a pointer to a
+// slice of structs, and returns v as CSV.
+func FromNDJSONAs(b []byte, v interface{}, opts ...csv.EncoderOption) ([]byte, error) {
+	slice, err := sliceOf(v)
+	if err != nil {
+		return nil, err
+	}
+	slice.Set(slice.Slice(0, 0))
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		elem := reflect.New(slice.Type().Elem())
+		err := dec.Decode(elem.Interface())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jsoncsv: record %d: %v", slice.Len()+1, err)
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+	return csv.Marshal(slice.Interface(), opts...)
+}
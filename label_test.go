@@ -0,0 +1,65 @@
+package csv
+
+import (
+	"reflect"
+	"testing"
+)
+
+type labeled struct {
+	Email string `csv:"email" csvlabel:"Customer Email Address"`
+	Qty   int    `csv:"qty"`
+	Addr  struct {
+		City string `csv:"city" csvlabel:"City"`
+	} `csv:"addr"`
+}
+
+var german = map[string]string{"email": "E-Mail", "qty": "Menge"}
+
+func TestEncodeLabels(t *testing.T) {
+	in := []labeled{{Email: "a@b.c", Qty: 2}}
+	in[0].Addr.City = "x"
+	tests := []struct {
+		opts []EncoderOption
+		want string
+	}{
+		{nil, "Customer Email Address,qty,City\na@b.c,2,x"},
+		// the labeler wins, falling back to the tag and then the path
+		{[]EncoderOption{LabelHeaders(func(p string) string { return german[p] })}, "E-Mail,Menge,City\na@b.c,2,x"},
+		// ordering still goes by path
+		{[]EncoderOption{OrderColumns("addr.city")}, "City,Customer Email Address,qty\nx,a@b.c,2"},
+	}
+	for _, tt := range tests {
+		b, err := Marshal(in, tt.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("got %q, want %q", b, tt.want)
+		}
+	}
+}
+
+func TestDecodeLabels(t *testing.T) {
+	want := labeled{Email: "a@b.c", Qty: 2}
+	want.Addr.City = "x"
+	tests := []struct {
+		header string
+		opts   []DecoderOption
+	}{
+		{"email,qty,addr.city", nil},
+		{"Customer Email Address,qty,City", nil},
+		{"E-Mail,Menge,City", []DecoderOption{ParseLabels(func(p string) string { return german[p] })}},
+		// paths and labels can be mixed
+		{"email,Menge,City", []DecoderOption{ParseLabels(func(p string) string { return german[p] })}},
+	}
+	for _, tt := range tests {
+		var got []labeled
+		if err := Unmarshal([]byte(tt.header+"\na@b.c,2,x"), &got, tt.opts...); err != nil {
+			t.Errorf("%q: %v", tt.header, err)
+			continue
+		}
+		if len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+			t.Errorf("%q: got %+v, want %+v", tt.header, got, want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package csv
+
+import "github.com/xiphoid24/csv/internal/shared"
+
+// Limits bounds the input a decoder accepts, for reading untrusted
+// uploads. Zero fields are unlimited. MaxBytes counts compressed input
+// after decompression, so a small upload cannot expand without bound.
+type Limits = shared.Limits
+
+// LimitError reports input over one of a decoder's Limits. Limit names the
+// Limits field and Row the record at fault, with the header at row 0. Row
+// is not set when MaxBytes is exceeded.
+type LimitError = shared.LimitError
@@ -0,0 +1,56 @@
+package csv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	b := []byte("a,b\n1,2\n3,4\n5,6789")
+	tests := []struct {
+		limits Limits
+		limit  string
+		row    int
+	}{
+		{Limits{MaxRows: 3}, "MaxRows", 3},
+		{Limits{MaxColumns: 1}, "MaxColumns", 0},
+		{Limits{MaxFieldBytes: 3}, "MaxFieldBytes", 3},
+		{Limits{MaxBytes: 10}, "MaxBytes", 0},
+		{Limits{MaxRows: 4, MaxColumns: 2, MaxFieldBytes: 4, MaxBytes: int64(len(b))}, "", 0},
+	}
+	for _, tt := range tests {
+		_, err := NewCSVDecoder(b, WithLimits(tt.limits))
+		if tt.limit == "" {
+			if err != nil {
+				t.Errorf("%+v: %v", tt.limits, err)
+			}
+			continue
+		}
+		le, ok := err.(*LimitError)
+		if !ok || le.Limit != tt.limit || le.Row != tt.row {
+			t.Errorf("%+v: got %v, want %s at row %d", tt.limits, err, tt.limit, tt.row)
+		}
+	}
+}
+
+func TestLimitsReader(t *testing.T) {
+	tests := []struct {
+		limits Limits
+		limit  string
+	}{
+		{Limits{MaxBytes: 7}, "MaxBytes"},
+		{Limits{MaxRows: 2}, "MaxRows"},
+	}
+	for _, tt := range tests {
+		type row struct {
+			Name string `csv:"name"`
+		}
+		var out []row
+		err := UnmarshalReader(strings.NewReader("name\na\nb"), &out, WithLimits(tt.limits))
+		var le *LimitError
+		if !errors.As(err, &le) || le.Limit != tt.limit {
+			t.Errorf("%+v: error = %v, want %s exceeded", tt.limits, err, tt.limit)
+		}
+	}
+}
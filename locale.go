@@ -0,0 +1,208 @@
+package csv
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// NumberLocale describes how numbers are written in a file. A decoder with
+// a locale accepts grouping separators between groups of three digits, the
+// locale's decimal separator, currency symbols and a trailing percent sign.
+// An encoder with a locale writes numbers the same way.
+type NumberLocale struct {
+	// Group separates thousands, e.g. "," in "1,234.50".
+	Group string
+	// Decimal separates the fraction, e.g. "," in "1.234,50".
+	Decimal string
+	// Currency is written in front of every number by the encoder, or
+	// after it when CurrencyAfter is set. The decoder strips it along
+	// with any other currency symbol.
+	Currency      string
+	CurrencyAfter bool
+}
+
+// LocaleUS writes "1,234.50".
+func LocaleUS() *NumberLocale { return &NumberLocale{Group: ",", Decimal: "."} }
+
+// LocaleEU writes "1.234,50".
+func LocaleEU() *NumberLocale { return &NumberLocale{Group: ".", Decimal: ","} }
+
+// LocaleFR writes "1 234,50".
+func LocaleFR() *NumberLocale { return &NumberLocale{Group: " ", Decimal: ","} }
+
+// LocaleCH writes "1'234.50".
+func LocaleCH() *NumberLocale { return &NumberLocale{Group: "'", Decimal: "."} }
+
+// locales are the names accepted by the locale tag option.
+var locales = map[string]func() *NumberLocale{
+	"us": LocaleUS,
+	"en": LocaleUS,
+	"eu": LocaleEU,
+	"de": LocaleEU,
+	"es": LocaleEU,
+	"it": LocaleEU,
+	"nl": LocaleEU,
+	"fr": LocaleFR,
+	"ch": LocaleCH,
+}
+
+// numberLocale returns the locale for a field, preferring the locale and
+// currency tag options over the coder-wide default.
+func numberLocale(def *NumberLocale, opts tagOptions) (*NumberLocale, error) {
+	loc := def
+	if name, ok := opts.Get("locale"); ok {
+		fn, ok := locales[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown locale %q", name)
+		}
+		loc = fn()
+	}
+	if cur, ok := opts.Get("currency"); ok {
+		l := NumberLocale{Group: "", Decimal: "."}
+		if loc != nil {
+			l = *loc
+		}
+		l.Currency = cur
+		loc = &l
+	}
+	return loc, nil
+}
+
+// normalizeNumber turns a localized number into the plain form strconv
+// understands. It reports whether the value carried a percent sign.
+func normalizeNumber(s string, loc *NumberLocale) (string, bool, error) {
+	s = strings.TrimSpace(s)
+	percent := strings.HasSuffix(s, "%")
+	if percent {
+		s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+	}
+	if loc == nil {
+		return s, percent, nil
+	}
+
+	if loc.Currency != "" {
+		s = strings.Replace(s, loc.Currency, "", -1)
+	}
+	s = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Sc, r) {
+			return -1
+		}
+		return r
+	}, s))
+	if strings.IndexFunc(s, unicode.IsSpace) >= 0 {
+		// spaces inside a number can only be a space group separator
+		if loc.Group == "" || strings.TrimSpace(loc.Group) != "" {
+			return "", false, errNumber
+		}
+		s = strings.Join(strings.Fields(s), loc.Group)
+	}
+
+	decimal := loc.Decimal
+	if decimal == "" {
+		decimal = "."
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	intPart, frac := s, ""
+	i := strings.Index(s, decimal)
+	if i >= 0 {
+		intPart, frac = s[:i], s[i+len(decimal):]
+	}
+	if loc.Group != "" {
+		if strings.Contains(frac, loc.Group) || !validGroups(intPart, loc.Group) {
+			return "", false, errNumber
+		}
+		intPart = strings.Replace(intPart, loc.Group, "", -1)
+	}
+	if decimal != "." && strings.Contains(intPart+frac, ".") {
+		return "", false, errNumber
+	}
+	s = sign + intPart
+	if i >= 0 {
+		s += "." + frac
+	}
+	return s, percent, nil
+}
+
+// validGroups reports whether the group separators of an integer part, if
+// any, split it into groups of three digits after a leading group of one
+// to three.
+func validGroups(s, group string) bool {
+	parts := strings.Split(s, group)
+	if len(parts) == 1 {
+		return true
+	}
+	if len(parts[0]) < 1 || len(parts[0]) > 3 {
+		return false
+	}
+	for _, p := range parts[1:] {
+		if len(p) != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+// shiftDecimal multiplies a plain decimal number by 10^n by moving its
+// decimal point, which avoids the rounding noise of multiplying a float.
+func shiftDecimal(s string, n int) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+	for len(frac) < n {
+		frac += "0"
+	}
+	intPart, frac = strings.TrimLeft(intPart+frac[:n], "0"), frac[n:]
+	if intPart == "" {
+		intPart = "0"
+	}
+	if frac != "" {
+		return sign + intPart + "." + frac
+	}
+	return sign + intPart
+}
+
+// localizeNumber rewrites a plain number produced by strconv using the
+// locale's separators and currency symbol.
+func localizeNumber(s string, loc *NumberLocale) string {
+	if loc == nil || strings.ContainsAny(s, "eEnN") {
+		return s
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(loc.Group)
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteString(loc.Decimal)
+		b.WriteString(frac)
+	}
+
+	switch {
+	case loc.Currency == "":
+		return sign + b.String()
+	case loc.CurrencyAfter:
+		return sign + b.String() + " " + loc.Currency
+	}
+	return sign + loc.Currency + b.String()
+}
@@ -0,0 +1,127 @@
+package csv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeNumber(t *testing.T) {
+	tests := []struct {
+		in      string
+		loc     *NumberLocale
+		want    string
+		percent bool
+		err     bool
+	}{
+		{"1234.5", nil, "1234.5", false, false},
+		{" 15% ", nil, "15", true, false},
+		{"1,234.50", LocaleUS(), "1234.50", false, false},
+		{"$1,234.50", LocaleUS(), "1234.50", false, false},
+		{"1.234,50", LocaleEU(), "1234.50", false, false},
+		{"1.234,50 €", LocaleEU(), "1234.50", false, false},
+		{"1 234,5", LocaleFR(), "1234.5", false, false},
+		{"1'234.5", LocaleCH(), "1234.5", false, false},
+		{"12,5 %", LocaleEU(), "12.5", true, false},
+		{"CHF 10", &NumberLocale{Decimal: ".", Currency: "CHF"}, "10", false, false},
+		{"1.5", &NumberLocale{Decimal: ","}, "", false, true},
+		{"1.234,50", LocaleUS(), "", false, true},
+		{"1,2,3", LocaleUS(), "", false, true},
+		{"1.5", LocaleEU(), "", false, true},
+		{"12,34.5", LocaleUS(), "", false, true},
+		{"1 234.5", LocaleUS(), "", false, true},
+		{"-1,234,567", LocaleUS(), "-1234567", false, false},
+		{"123", LocaleUS(), "123", false, false},
+		{"1\u00a0234,5", LocaleFR(), "1234.5", false, false},
+		{"12 34,5", LocaleFR(), "", false, true},
+	}
+	for _, tt := range tests {
+		got, percent, err := normalizeNumber(tt.in, tt.loc)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: err = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want || percent != tt.percent {
+			t.Errorf("%q: got %q, %v, want %q, %v", tt.in, got, percent, tt.want, tt.percent)
+		}
+	}
+}
+
+func TestLocalizeNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		loc  *NumberLocale
+		want string
+	}{
+		{"1234.5", nil, "1234.5"},
+		{"1234567.25", LocaleUS(), "1,234,567.25"},
+		{"-1234.5", LocaleEU(), "-1.234,5"},
+		{"123", LocaleFR(), "123"},
+		{"1234", LocaleCH(), "1'234"},
+		{"1e+21", LocaleUS(), "1e+21"},
+		{"99", &NumberLocale{Group: ",", Decimal: ".", Currency: "$"}, "$99"},
+		{"1234.5", &NumberLocale{Group: ".", Decimal: ",", Currency: "€", CurrencyAfter: true}, "1.234,5 €"},
+	}
+	for _, tt := range tests {
+		if got := localizeNumber(tt.in, tt.loc); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestShiftDecimal(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"15", 2, "1500"},
+		{"0.155", 2, "15.5"},
+		{"-0.5", 2, "-50"},
+		{"0.001", 2, "0.1"},
+	}
+	for _, tt := range tests {
+		if got := shiftDecimal(tt.in, tt.n); got != tt.want {
+			t.Errorf("shiftDecimal(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLocaleTags(t *testing.T) {
+	type row struct {
+		Price float64 `csv:"price,locale=eu,currency=€"`
+		Qty   int     `csv:"qty,locale=us"`
+		Rate  float64 `csv:"rate,percent"`
+	}
+	in := []byte("price,qty,rate\n\"€1.234,5\",\"12,000\",15%")
+	var got []row
+	if err := Unmarshal(in, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []row{{1234.5, 12000, 0.15}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Unmarshal = %+v, want %+v", got, want)
+	}
+	b, err := Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := "price,qty,rate\n\"€1.234,5\",\"12,000\",15%"; string(b) != s {
+		t.Errorf("Marshal = %q, want %q", b, s)
+	}
+
+	// field options win over the decoder-wide locale
+	var plain []row
+	if err := Unmarshal([]byte("price,qty,rate\n\"1,5\",\"1,000\",3"), &plain, ParseNumbers(LocaleFR())); err != nil {
+		t.Fatal(err)
+	}
+	if want := []row{{1.5, 1000, 3}}; !reflect.DeepEqual(plain, want) {
+		t.Errorf("ParseNumbers = %+v, want %+v", plain, want)
+	}
+	type unknown struct {
+		N int `csv:"n,locale=xx"`
+	}
+	var u []unknown
+	if err := Unmarshal([]byte("n\n1"), &u); err == nil {
+		t.Error("expected an error for an unknown locale")
+	}
+}
@@ -0,0 +1,75 @@
+package csv
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RowMarshaler is implemented by types that write their own cells, usually
+// through methods generated by cmd/csvgen. MarshalCSVRow returns one cell
+// per column, in the order the encoder lays out the columns from the
+// type's fields; Columns, Select and order tags are applied afterwards.
+type RowMarshaler interface {
+	MarshalCSVRow() ([]string, error)
+}
+
+// RowUnmarshaler is implemented by types that read their own cells, usually
+// through methods generated by cmd/csvgen. header maps header labels to
+// column indexes. filled reports whether any of the type's columns had a
+// value, which drives the empty-row policy.
+type RowUnmarshaler interface {
+	UnmarshalCSVRow(header map[string]int, row []string) (filled bool, err error)
+}
+
+// Generated methods have the tag options compiled in but know nothing of
+// coder-wide settings, so they are only used while those are left at their
+// defaults. Any other setting falls back to reflection.
+
+func (c *CSVDecoder) defaults() bool {
+	return c.IntBase == 10 && c.TrueString == "" && c.FalseString == "" &&
+		c.Locale == nil && len(c.NullTokens) == 0 && c.TimeLayout == "" &&
+		c.selected == nil && c.Labeler == nil && !c.UnescapeFormulas
+}
+
+func (c *CSVEncoder) defaults() bool {
+	return c.FloatFormat == 0 && c.FloatPrecision == -1 && c.IntBase == 10 &&
+		c.TrueString == "true" && c.FalseString == "false" &&
+		c.Locale == nil && c.NullToken == "" && c.TimeLayout == "" &&
+		!c.EscapeFormulas
+}
+
+// decodeInto decodes a row into strct, through its UnmarshalCSVRow method
+// when it has one.
+func (c *CSVDecoder) decodeInto(rowNum int, strct reflect.Value) error {
+	if u, ok := strct.Addr().Interface().(RowUnmarshaler); ok && c.defaults() {
+		filled, err := u.UnmarshalCSVRow(c.HeaderMap, c.Rows[rowNum])
+		c.RowFilled = c.RowFilled || filled
+		return err
+	}
+	if c.plan != nil && strct.Type() == c.planType {
+		return c.decodePlanned(rowNum, strct)
+	}
+	return c.DecodeRow(rowNum, "", strct)
+}
+
+// encodeCells fills RowCache with the cells of strctVal, through its
+// MarshalCSVRow method when it has one.
+func (c *CSVEncoder) encodeCells(strctVal reflect.Value) error {
+	v := strctVal.Interface()
+	if strctVal.CanAddr() {
+		v = strctVal.Addr().Interface()
+	}
+	if m, ok := v.(RowMarshaler); ok && c.defaults() {
+		cells, err := m.MarshalCSVRow()
+		if err != nil {
+			return err
+		}
+		if len(cells) != c.width {
+			return fmt.Errorf("csv: %s.MarshalCSVRow returned %d cells, want %d", strctVal.Type(), len(cells), c.width)
+		}
+		c.RowCache = cells
+		return nil
+	}
+	c.RowCache = []string{}
+	return c.EncodeRow(strctVal, "")
+}
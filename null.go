@@ -0,0 +1,53 @@
+package csv
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"time"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// isScalarStruct reports whether a struct type is written to a single
+// column instead of being flattened into nested columns. This is the case
+// for time.Time and for database/sql style types such as sql.NullString
+// that implement both sql.Scanner and driver.Valuer.
+func isScalarStruct(t reflect.Type) bool {
+	return t == timeType || isScanner(t)
+}
+
+func isScanner(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && reflect.PtrTo(t).Implements(scannerType) && t.Implements(valuerType)
+}
+
+// nullFields returns the value and Valid fields of a nullable struct shaped
+// like sql.NullString or sql.Null[T]: a Valid bool next to a single value
+// field. ok is false for any other shape.
+func nullFields(v reflect.Value) (val, valid reflect.Value, ok bool) {
+	t := v.Type()
+	if t.Kind() != reflect.Struct || t.NumField() != 2 {
+		return val, valid, false
+	}
+	for i := 0; i < 2; i++ {
+		f := t.Field(i)
+		if f.Name == "Valid" && f.Type.Kind() == reflect.Bool {
+			return v.Field(1 - i), v.Field(i), t.Field(1-i).PkgPath == ""
+		}
+	}
+	return val, valid, false
+}
+
+func timeLayout(def string, opts tagOptions) string {
+	if layout, ok := opts.Get("layout"); ok {
+		return layout
+	}
+	if def != "" {
+		return def
+	}
+	return time.RFC3339
+}
@@ -0,0 +1,87 @@
+package csv
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type nullRow struct {
+	Name  sql.NullString  `csv:"name"`
+	Qty   sql.NullInt64   `csv:"qty"`
+	Price sql.NullFloat64 `csv:"price"`
+	OK    sql.NullBool    `csv:"ok"`
+	When  sql.NullTime    `csv:"when,layout=2006-01-02"`
+	Note  string          `csv:"note"`
+}
+
+func TestDecodeNulls(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	full := nullRow{
+		sql.NullString{String: "a", Valid: true},
+		sql.NullInt64{Int64: 2, Valid: true},
+		sql.NullFloat64{Float64: 1.5, Valid: true},
+		sql.NullBool{Bool: true, Valid: true},
+		sql.NullTime{Time: day, Valid: true},
+		"x",
+	}
+	tests := []struct {
+		in     string
+		tokens []string
+		want   nullRow
+	}{
+		{"a,2,1.5,true,2024-03-01,x", nil, full},
+		// without tokens an empty cell is null
+		{",,,,,x", nil, nullRow{Note: "x"}},
+		{"NULL,\\N,N/A,-,NULL,x", []string{"NULL", `\N`, "N/A", "-"}, nullRow{Note: "x"}},
+		// with tokens an empty cell is an empty string
+		{",NULL,NULL,NULL,NULL,x", []string{"NULL"}, nullRow{Name: sql.NullString{Valid: true}, Note: "x"}},
+		// a null token in a plain field leaves it at its zero value
+		{"a,2,1.5,true,2024-03-01,NULL", []string{"NULL"}, func() nullRow { r := full; r.Note = ""; return r }()},
+	}
+	for _, tt := range tests {
+		var got []nullRow
+		if err := Unmarshal([]byte("name,qty,price,ok,when,note\n"+tt.in), &got, ParseNulls(tt.tokens...)); err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if len(got) != 1 || !reflect.DeepEqual(got[0], tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeNulls(t *testing.T) {
+	in := []nullRow{
+		{Name: sql.NullString{Valid: true}, Qty: sql.NullInt64{Int64: 3, Valid: true}, Note: "x"},
+	}
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"", "name,qty,price,ok,when,note\n,3,,,,x"},
+		{"NULL", "name,qty,price,ok,when,note\n,3,NULL,NULL,NULL,x"},
+	}
+	for _, tt := range tests {
+		b, err := Marshal(in, FormatNulls(tt.token))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("token %q: got %q, want %q", tt.token, b, tt.want)
+		}
+	}
+
+	b, err := Marshal(in, FormatNulls("NULL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back []nullRow
+	if err := Unmarshal(b, &back, ParseNulls("NULL")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, in) {
+		t.Errorf("round trip = %+v, want %+v", back, in)
+	}
+}
@@ -0,0 +1,224 @@
+package csv
+
+import "github.com/xiphoid24/csv/internal/shared"
+
+// EmptyRowPolicy controls what Decode does with a row that leaves every
+// field empty.
+type EmptyRowPolicy = shared.EmptyRowPolicy
+
+const (
+	// SkipEmptyRows drops empty rows. It is the default.
+	SkipEmptyRows = shared.SkipEmptyRows
+	// KeepEmptyRows appends a zero value for each empty row, so the output
+	// stays aligned with the records of the input. Blank lines are not
+	// records; RowError.Line gives the line of a record.
+	KeepEmptyRows = shared.KeepEmptyRows
+	// ErrorEmptyRows stops decoding with a *RowError wrapping EMPTYROW.
+	ErrorEmptyRows = shared.ErrorEmptyRows
+)
+
+// DecoderOption configures a CSVDecoder before any rows are decoded.
+type DecoderOption func(*CSVDecoder)
+
+// WithEmptyRows sets the policy applied to rows without any values.
+func WithEmptyRows(p EmptyRowPolicy) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.EmptyRows = p
+	}
+}
+
+// WithValidator sets a hook that Decode calls with the row number and a
+// pointer to each decoded element. A non-nil error stops decoding and is
+// returned as a *RowError.
+func WithValidator(fn func(row int, v interface{}) error) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.Validate = fn
+	}
+}
+
+// WithLimits rejects input over l with a *LimitError while it is read.
+func WithLimits(l Limits) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.Limits = l
+	}
+}
+
+// ParseInts sets the base integers are parsed in. Fields can override it
+// with the base tag option.
+func ParseInts(base int) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.IntBase = base
+	}
+}
+
+// ParseBools accepts t and f, compared case-insensitively, as true and
+// false. Fields can override them with the true and false tag options.
+func ParseBools(t, f string) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.TrueString, c.FalseString = t, f
+	}
+}
+
+// EncoderOption configures a CSVEncoder before the header is written.
+type EncoderOption func(*CSVEncoder)
+
+// FormatFloats writes floats with strconv.FormatFloat using format and
+// prec. Fields can override them with the format and prec tag options.
+func FormatFloats(format byte, prec int) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.FloatFormat, c.FloatPrecision = format, prec
+	}
+}
+
+// FormatInts writes integers in base. Fields can override it with the base
+// tag option.
+func FormatInts(base int) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.IntBase = base
+	}
+}
+
+// FormatBools writes booleans as t and f. Fields can override them with the
+// true and false tag options.
+func FormatBools(t, f string) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.TrueString, c.FalseString = t, f
+	}
+}
+
+// ParseNumbers decodes numbers written in loc. Fields can override it with
+// the locale and currency tag options.
+func ParseNumbers(loc *NumberLocale) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.Locale = loc
+	}
+}
+
+// FormatNumbers writes numbers in loc. Fields can override it with the
+// locale and currency tag options.
+func FormatNumbers(loc *NumberLocale) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.Locale = loc
+	}
+}
+
+// ParseNulls treats each of tokens, such as "NULL" or `\N`, as a missing
+// value. An empty cell then decodes to a valid empty sql.NullString.
+func ParseNulls(tokens ...string) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.NullTokens = tokens
+	}
+}
+
+// FormatNulls writes token for invalid nullable values.
+func FormatNulls(token string) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.NullToken = token
+	}
+}
+
+// ParseTimes parses time fields with layout instead of time.RFC3339.
+// Fields can override it with the layout tag option.
+func ParseTimes(layout string) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.TimeLayout = layout
+	}
+}
+
+// FormatTimes writes time fields with layout instead of time.RFC3339.
+// Fields can override it with the layout tag option.
+func FormatTimes(layout string) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.TimeLayout = layout
+	}
+}
+
+// OrderColumns writes the named columns first, in the given order. Columns
+// are named by their dotted header path, e.g. "Address.City".
+func OrderColumns(cols ...string) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.Columns = cols
+	}
+}
+
+// SelectColumns writes only the named columns, in the given order. Columns
+// are named by their dotted header path, e.g. "Address.City".
+func SelectColumns(cols ...string) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.Select = cols
+	}
+}
+
+// DecodeColumns decodes only the named columns and leaves every other
+// field at its zero value. Columns are named by their dotted header path,
+// like SelectColumns, and naming a column the type does not have is an
+// error.
+func DecodeColumns(cols ...string) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.Select = cols
+	}
+}
+
+// LabelHeaders writes fn(path) as the header of each column instead of its
+// path. It can be used to localize headers; returning "" falls back to the
+// csvlabel tag or the path.
+func LabelHeaders(fn func(path string) string) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.Labeler = fn
+	}
+}
+
+// ParseLabels accepts fn(path) as a header in addition to the column path
+// and the csvlabel tag.
+func ParseLabels(fn func(path string) string) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.Labeler = fn
+	}
+}
+
+// ParseDelimiter reads fields separated by r instead of a comma.
+func ParseDelimiter(r rune) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.Comma = r
+	}
+}
+
+// FormatDelimiter writes fields separated by r instead of a comma.
+func FormatDelimiter(r rune) EncoderOption {
+	return func(c *CSVEncoder) {
+		c.Comma = r
+	}
+}
+
+// Workers converts rows on n goroutines. Decoding stays single-threaded
+// when n is one or less.
+func Workers(n int) DecoderOption {
+	return func(c *CSVDecoder) {
+		c.Workers = n
+	}
+}
+
+// EscapeFormulas prefixes string cells starting with =, +, -, @, a tab or a
+// carriage return with a single quote, so spreadsheets show them as text
+// instead of running them as formulas.
+func EscapeFormulas() EncoderOption {
+	return func(c *CSVEncoder) {
+		c.EscapeFormulas = true
+	}
+}
+
+// UnescapeFormulas removes the quote EscapeFormulas adds, giving back the
+// original strings.
+func UnescapeFormulas() DecoderOption {
+	return func(c *CSVDecoder) {
+		c.UnescapeFormulas = true
+	}
+}
+
+// GzipOutput compresses the output with gzip. Decoders recognize gzip input
+// by itself, so no option is needed to read it back.
+func GzipOutput() EncoderOption {
+	return func(c *CSVEncoder) {
+		c.Gzip = true
+	}
+}
@@ -0,0 +1,81 @@
+package csv
+
+import (
+	"fmt"
+	"sort"
+)
+
+// columnOrder works out the order the header columns are written in.
+// Columns named in c.Columns come first, in that order, followed by columns
+// with an order tag option sorted by it, followed by the rest in
+// declaration order. It returns nil when the declaration order is kept.
+// When c.Select is set only the selected columns are written, in the order
+// given.
+func (c *CSVEncoder) columnOrder(header []string) ([]int, error) {
+	if len(c.Columns) == 0 && len(c.orderTags) == 0 && len(c.Select) == 0 {
+		return nil, nil
+	}
+
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		index[h] = i
+	}
+
+	if len(c.Select) > 0 {
+		order := make([]int, 0, len(c.Select))
+		for _, col := range c.Select {
+			i, ok := index[col]
+			if !ok {
+				return nil, fmt.Errorf("csv: unknown column %q", col)
+			}
+			order = append(order, i)
+		}
+		return order, nil
+	}
+
+	used := make([]bool, len(header))
+	order := make([]int, 0, len(header))
+	for _, col := range c.Columns {
+		i, ok := index[col]
+		if !ok {
+			return nil, fmt.Errorf("csv: unknown column %q", col)
+		}
+		if !used[i] {
+			used[i] = true
+			order = append(order, i)
+		}
+	}
+
+	var tagged []int
+	for i := range header {
+		if _, ok := c.orderTags[i]; ok && !used[i] {
+			tagged = append(tagged, i)
+		}
+	}
+	sort.SliceStable(tagged, func(a, b int) bool {
+		return c.orderTags[tagged[a]] < c.orderTags[tagged[b]]
+	})
+	for _, i := range tagged {
+		used[i] = true
+		order = append(order, i)
+	}
+
+	for i := range header {
+		if !used[i] {
+			order = append(order, i)
+		}
+	}
+	return order, nil
+}
+
+// permute rearranges a row built in declaration order into output order.
+func (c *CSVEncoder) permute(cells []string) []string {
+	if c.order == nil {
+		return cells
+	}
+	out := make([]string, len(c.order))
+	for i, j := range c.order {
+		out[i] = cells[j]
+	}
+	return out
+}
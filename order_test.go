@@ -0,0 +1,101 @@
+package csv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColumnOrder(t *testing.T) {
+	type addr struct {
+		City string `csv:"city"`
+		Zip  string `csv:"zip,order=1"`
+	}
+	type row struct {
+		Name string `csv:"name,order=2"`
+		Addr addr   `csv:"addr"`
+		ID   int    `csv:"id,order=0"`
+		Note string `csv:"note"`
+	}
+	// reordering the fields leaves the tagged columns where they were
+	type moved struct {
+		Note string `csv:"note"`
+		ID   int    `csv:"id,order=0"`
+		Addr addr   `csv:"addr"`
+		Name string `csv:"name,order=2"`
+	}
+	tests := []struct {
+		v    interface{}
+		opts []EncoderOption
+		want string
+		err  string
+	}{
+		{[]row{{"a", addr{"c", "z"}, 1, "n"}}, nil, "id,addr.zip,name,addr.city,note\n1,z,a,c,n", ""},
+		{[]moved{{"n", 1, addr{"c", "z"}, "a"}}, nil, "id,addr.zip,name,note,addr.city\n1,z,a,n,c", ""},
+		{[]row{{"a", addr{"c", "z"}, 1, "n"}}, []EncoderOption{OrderColumns("note", "addr.city")}, "note,addr.city,id,addr.zip,name\nn,c,1,z,a", ""},
+		{[]row{{"a", addr{"c", "z"}, 1, "n"}}, []EncoderOption{SelectColumns("name", "id")}, "name,id\na,1", ""},
+		{[]row{{}}, []EncoderOption{OrderColumns("nosuch")}, "", `unknown column "nosuch"`},
+		{[]row{{}}, []EncoderOption{SelectColumns("nosuch")}, "", `unknown column "nosuch"`},
+	}
+	for i, tt := range tests {
+		b, err := Marshal(tt.v, tt.opts...)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%d: got %v, want %s", i, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("%d: got %q, want %q", i, b, tt.want)
+		}
+	}
+}
+
+func TestDecodeColumns(t *testing.T) {
+	type addr struct {
+		City string `csv:"city"`
+	}
+	type row struct {
+		Name string `csv:"name"`
+		Qty  int    `csv:"qty"`
+		Addr addr   `csv:"addr"`
+	}
+	in := []byte("name,qty,addr.city\na,not a number,c")
+	tests := []struct {
+		cols []string
+		want row
+		err  string
+	}{
+		// qty is never converted, so its bad value is no error
+		{[]string{"name", "addr.city"}, row{Name: "a", Addr: addr{"c"}}, ""},
+		{[]string{"name"}, row{Name: "a"}, ""},
+		{[]string{"qty"}, row{}, "must be a number"},
+		{[]string{"addr"}, row{}, `unknown column "addr"`},
+		{[]string{"nosuch"}, row{}, `unknown column "nosuch"`},
+	}
+	for _, tt := range tests {
+		var got []row
+		err := Unmarshal(in, &got, DecodeColumns(tt.cols...))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: got %v, want %s", tt.cols, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.cols, err)
+			continue
+		}
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%q: got %+v, want %+v", tt.cols, got, tt.want)
+		}
+	}
+
+	var one row
+	if err := UnmarshalRow(1, in, &one, DecodeColumns("nosuch")); err == nil {
+		t.Error("UnmarshalRow: expected an unknown column error")
+	}
+}
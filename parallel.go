@@ -0,0 +1,76 @@
+package csv
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// rowsPerChunk is how many rows a worker claims at a time.
+const rowsPerChunk = 256
+
+// decodeParallel converts the data rows on c.Workers goroutines. Workers
+// claim chunks of rows in order and each uses its own copy of the decoder,
+// so RowFilled is never shared. After an error no rows past it are
+// converted, and the error returned is the one for the earliest row, with
+// the rows before it appended, just as Decode would leave them.
+func (c *CSVDecoder) decodeParallel(val reflect.Value, strctTyp reflect.Type) error {
+	n := len(c.Rows) - 1
+	elems := make([]reflect.Value, n)
+	keep := make([]bool, n)
+
+	var (
+		next   int64
+		errRow int64 = math.MaxInt64
+		mu     sync.Mutex
+		first  error
+		wg     sync.WaitGroup
+	)
+
+	fail := func(rowNum int, err error) {
+		mu.Lock()
+		if int64(rowNum) < atomic.LoadInt64(&errRow) {
+			atomic.StoreInt64(&errRow, int64(rowNum))
+			first = err
+		}
+		mu.Unlock()
+	}
+
+	for w := 0; w < c.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := *c
+			for {
+				start := int(atomic.AddInt64(&next, rowsPerChunk)) - rowsPerChunk + 1
+				if start > n || int64(start) > atomic.LoadInt64(&errRow) {
+					return
+				}
+				for rowNum := start; rowNum < start+rowsPerChunk && rowNum <= n; rowNum++ {
+					if int64(rowNum) > atomic.LoadInt64(&errRow) {
+						return
+					}
+					strct, ok, err := d.decodeElem(rowNum, strctTyp)
+					if err != nil {
+						fail(rowNum, err)
+						return
+					}
+					elems[rowNum-1], keep[rowNum-1] = strct, ok
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// every row before the first error has been converted
+	for i, strct := range elems {
+		if int64(i+1) >= errRow {
+			break
+		}
+		if keep[i] {
+			val.Set(reflect.Append(val, strct))
+		}
+	}
+	return first
+}
@@ -0,0 +1,52 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeParallel(t *testing.T) {
+	type row struct {
+		ID   int
+		Name string
+		Qty  float64
+	}
+	var b strings.Builder
+	b.WriteString("ID,Name,Qty\n")
+	for i := 0; i < 3000; i++ {
+		if i%97 == 0 {
+			b.WriteString(",,\n")
+			continue
+		}
+		fmt.Fprintf(&b, "%d,n%d,%d.5\n", i, i, i)
+	}
+	good := []byte(b.String())
+	// rows 1500 and 2700 are bad; 1500 is reported in both modes
+	bad := []byte(strings.Replace(strings.Replace(b.String(), "\n2699,", "\nx,", 1), "\n1499,", "\ny,", 1))
+
+	for _, in := range [][]byte{good, bad} {
+		for _, policy := range []EmptyRowPolicy{SkipEmptyRows, KeepEmptyRows} {
+			var seq, par []row
+			seqErr := Unmarshal(in, &seq, WithEmptyRows(policy))
+			parErr := Unmarshal(in, &par, WithEmptyRows(policy), Workers(4))
+			if fmt.Sprint(seqErr) != fmt.Sprint(parErr) {
+				t.Errorf("policy %d: errors differ: %v, %v", policy, seqErr, parErr)
+			}
+			if !reflect.DeepEqual(seq, par) {
+				t.Errorf("policy %d: %d sequential rows, %d parallel rows", policy, len(seq), len(par))
+			}
+		}
+	}
+
+	var par []row
+	err := Unmarshal(bad, &par, Workers(4))
+	var re *RowError
+	if errors.As(err, &re) && re.Row != 1500 {
+		t.Errorf("got error at row %d, want 1500", re.Row)
+	} else if err == nil {
+		t.Error("expected an error")
+	}
+}
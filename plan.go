@@ -0,0 +1,87 @@
+package csv
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// fieldPlan is how one scalar field of a struct type is decoded: where it
+// sits in the struct and what its tags say. Plans only depend on the type,
+// so a Config works them out once and shares them between calls.
+type fieldPlan struct {
+	index []int
+	path  string
+	label string
+	opts  tagOptions
+}
+
+// newDecodePlan lists the scalar fields of t, nested structs flattened, in
+// the order DecodeRow visits them.
+func newDecodePlan(t reflect.Type) []fieldPlan {
+	return appendFieldPlans(nil, t, nil, "")
+}
+
+func appendFieldPlans(plan []fieldPlan, t reflect.Type, index []int, start string) []fieldPlan {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, opts := parseTag(f.Tag.Get("csv"))
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		idx := append(append([]int{}, index...), i)
+		if f.Type.Kind() == reflect.Struct && !isScalarStruct(f.Type) {
+			plan = appendFieldPlans(plan, f.Type, idx, start+tag+".")
+			continue
+		}
+		plan = append(plan, fieldPlan{
+			index: idx,
+			path:  start + tag,
+			label: f.Tag.Get("csvlabel"),
+			opts:  opts,
+		})
+	}
+	return plan
+}
+
+// usePlan makes c decode elements of t with plan, matching each field to
+// its header column once instead of on every row. Selecting a column t
+// does not have is an error.
+func (c *CSVDecoder) usePlan(t reflect.Type, plan []fieldPlan) error {
+	if c.selected != nil {
+		paths := make(map[string]bool, len(plan))
+		for _, f := range plan {
+			paths[f.path] = true
+		}
+		for _, col := range c.Select {
+			if !paths[col] {
+				return fmt.Errorf("csv: unknown column %q", col)
+			}
+		}
+	}
+	c.plan, c.planType = plan, t
+	c.columns = make([]int, len(plan))
+	for i, f := range plan {
+		col, ok := c.column(f.path, f.label)
+		if !ok || c.selected != nil && !c.selected[f.path] {
+			col = -1
+		}
+		c.columns[i] = col
+	}
+	return nil
+}
+
+// decodePlanned decodes a row into strct following c.plan.
+func (c *CSVDecoder) decodePlanned(rowNum int, strct reflect.Value) error {
+	for i, f := range c.plan {
+		if c.columns[i] < 0 {
+			continue
+		}
+		if err := c.decodeField(rowNum, c.columns[i], strct.FieldByIndex(f.index), f.path, f.opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
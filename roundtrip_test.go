@@ -0,0 +1,122 @@
+package csv
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/xiphoid24/csv/internal/randtype"
+)
+
+// gen makes struct types with csv tags, including ignored fields.
+var gen = randtype.Gen{
+	Tag: func(r *rand.Rand, i int) reflect.StructTag {
+		switch r.Intn(4) {
+		case 0:
+			return reflect.StructTag(fmt.Sprintf(`csv:"col %d \"x\""`, i))
+		case 1:
+			return reflect.StructTag(fmt.Sprintf(`csv:"c%d"`, i))
+		case 2:
+			if i > 0 {
+				return `csv:"-"`
+			}
+		}
+		return ""
+	},
+	Skip: func(f reflect.StructField) bool {
+		return f.Tag.Get("csv") == "-"
+	},
+}
+
+// TestRoundTrip checks that Unmarshal reads back what Marshal writes.
+// Elements that encode to an empty row are only kept with KeepEmptyRows.
+func TestRoundTrip(t *testing.T) {
+	check := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		typ := gen.Type(r, 2)
+		in := gen.Slice(r, typ)
+
+		b, err := Marshal(in.Interface())
+		if err != nil {
+			t.Logf("seed %d: Marshal: %v", seed, err)
+			return false
+		}
+
+		nonEmpty := reflect.MakeSlice(in.Type(), 0, in.Len())
+		for i := 0; i < in.Len(); i++ {
+			if !gen.Empty(in.Index(i)) {
+				nonEmpty = reflect.Append(nonEmpty, in.Index(i))
+			}
+		}
+		for _, tt := range []struct {
+			policy EmptyRowPolicy
+			want   reflect.Value
+		}{
+			{SkipEmptyRows, nonEmpty},
+			{KeepEmptyRows, in},
+		} {
+			out := reflect.New(in.Type())
+			if err := Unmarshal(b, out.Interface(), WithEmptyRows(tt.policy)); err != nil {
+				t.Logf("seed %d: Unmarshal: %v\n%s", seed, err, b)
+				return false
+			}
+			got := out.Elem()
+			if got.Len() == 0 && tt.want.Len() == 0 {
+				continue
+			}
+			if !reflect.DeepEqual(tt.want.Interface(), got.Interface()) {
+				t.Logf("seed %d, policy %d: mismatch\n in: %+v\nout: %+v\n%s", seed, tt.policy, tt.want, got, b)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRoundTripSingleStruct(t *testing.T) {
+	check := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		typ := gen.Type(r, 2)
+		in := gen.Value(r, typ)
+
+		b, err := Marshal(in.Interface())
+		if err != nil {
+			t.Logf("seed %d: Marshal: %v", seed, err)
+			return false
+		}
+
+		out := reflect.New(typ)
+		if err := UnmarshalRow(1, b, out.Interface()); err != nil {
+			t.Logf("seed %d: UnmarshalRow: %v\n%s", seed, err, b)
+			return false
+		}
+		if !reflect.DeepEqual(in.Interface(), out.Elem().Interface()) {
+			t.Logf("seed %d: mismatch\n in: %+v\nout: %+v\n%s", seed, in, out.Elem(), b)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMarshalQuotes(t *testing.T) {
+	type row struct {
+		Name string `csv:"full \"name\""`
+		Note string
+	}
+	b, err := Marshal([]row{{"Doe, John", `said "hi"`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\"full \"\"name\"\"\",Note\n\"Doe, John\",\"said \"\"hi\"\"\""
+	if string(b) != want {
+		t.Errorf("Marshal = %q, want %q", b, want)
+	}
+}
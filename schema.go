@@ -0,0 +1,434 @@
+package csv
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ColumnType is the kind of value a schema column holds.
+type ColumnType string
+
+const (
+	TypeString ColumnType = "string"
+	TypeInt    ColumnType = "int"
+	TypeUint   ColumnType = "uint"
+	TypeFloat  ColumnType = "float"
+	TypeBool   ColumnType = "bool"
+	TypeTime   ColumnType = "time"
+)
+
+// Column describes one column of a Schema. Format is a time layout for time
+// columns, a number locale name such as "de" for numeric columns and a
+// "true/false" pair such as "Y/N" for bool columns. Min and Max bound
+// numeric columns like the min and max tag options.
+type Column struct {
+	Name     string     `json:"name"`
+	Type     ColumnType `json:"type"`
+	Nullable bool       `json:"nullable,omitempty"`
+	Format   string     `json:"format,omitempty"`
+	Min      string     `json:"min,omitempty"`
+	Max      string     `json:"max,omitempty"`
+}
+
+// Schema describes the columns of a file whose layout is only known at run
+// time. It decodes into Records with the same conversion rules Unmarshal
+// applies to struct fields.
+type Schema struct {
+	Columns []Column `json:"columns"`
+
+	// Delimiter is the field separator when it is not a comma. NoHeader
+	// marks files without a header row, whose columns are matched by
+	// position instead of by name.
+	Delimiter string `json:"delimiter,omitempty"`
+	NoHeader  bool   `json:"noHeader,omitempty"`
+}
+
+// Record holds the values of one row in schema column order. Values are
+// string, int64, uint64, float64, bool or time.Time, and nil for nulls.
+type Record []interface{}
+
+var columnTypes = map[ColumnType]reflect.Type{
+	TypeString: reflect.TypeOf(sql.NullString{}),
+	TypeInt:    reflect.TypeOf(sql.NullInt64{}),
+	TypeUint:   reflect.TypeOf(nullUint64{}),
+	TypeFloat:  reflect.TypeOf(sql.NullFloat64{}),
+	TypeBool:   reflect.TypeOf(sql.NullBool{}),
+	TypeTime:   reflect.TypeOf(sql.NullTime{}),
+}
+
+// nullUint64 fills the gap database/sql leaves for unsigned columns.
+type nullUint64 struct {
+	Uint64 uint64
+	Valid  bool
+}
+
+func (n *nullUint64) Scan(v interface{}) error {
+	if v == nil {
+		*n = nullUint64{}
+		return nil
+	}
+	u, err := strconv.ParseUint(fmt.Sprint(v), 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = nullUint64{Uint64: u, Valid: true}
+	return nil
+}
+
+func (n nullUint64) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return strconv.FormatUint(n.Uint64, 10), nil
+}
+
+// structType builds the struct a row decodes into. Every column becomes a
+// nullable field bound to the column path "c<index>", which keeps column
+// names with commas or dots out of the struct tags.
+func (s *Schema) structType() (reflect.Type, error) {
+	if len(s.Columns) == 0 {
+		return nil, fmt.Errorf("csv: schema has no columns")
+	}
+	fields := make([]reflect.StructField, len(s.Columns))
+	for i, col := range s.Columns {
+		t, ok := columnTypes[col.Type]
+		if !ok {
+			return nil, fmt.Errorf("csv: column %q has unknown type %q", col.Name, col.Type)
+		}
+		tag, err := col.tag(i)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = reflect.StructField{
+			Name: "C" + strconv.Itoa(i),
+			Type: t,
+			Tag:  reflect.StructTag(fmt.Sprintf("csv:%q", tag)),
+		}
+	}
+	return reflect.StructOf(fields), nil
+}
+
+func (c Column) tag(i int) (string, error) {
+	opts := []string{columnPath(i)}
+	switch {
+	case c.Format == "":
+	case c.Type == TypeTime:
+		opts = append(opts, "layout="+quoteTag(c.Format))
+	case c.Type == TypeBool:
+		tf := strings.SplitN(c.Format, "/", 2)
+		if len(tf) != 2 {
+			return "", fmt.Errorf("csv: column %q: bool format %q is not true/false", c.Name, c.Format)
+		}
+		opts = append(opts, "true="+quoteTag(tf[0]), "false="+quoteTag(tf[1]))
+	case c.Type != TypeString:
+		opts = append(opts, "locale="+quoteTag(c.Format))
+	}
+	if c.Min != "" {
+		opts = append(opts, "min="+quoteTag(c.Min))
+	}
+	if c.Max != "" {
+		opts = append(opts, "max="+quoteTag(c.Max))
+	}
+	return strings.Join(opts, ","), nil
+}
+
+// Parse converts a single cell to the column's type with the rules Decode
+// applies, returning nil for an empty cell. A value outside Min or Max, or
+// the range of the column type, is reported as a *RangeError.
+func (c Column) Parse(cell string) (interface{}, error) {
+	t, ok := columnTypes[c.Type]
+	if !ok {
+		return nil, fmt.Errorf("csv: column %q has unknown type %q", c.Name, c.Type)
+	}
+	tag, err := c.tag(0)
+	if err != nil {
+		return nil, err
+	}
+	strct := reflect.New(reflect.StructOf([]reflect.StructField{{
+		Name: "C0",
+		Type: t,
+		Tag:  reflect.StructTag(fmt.Sprintf("csv:%q", tag)),
+	}})).Elem()
+
+	dec := &CSVDecoder{
+		Rows:      [][]string{{c.Name}, {cell}},
+		HeaderMap: map[string]int{columnPath(0): 0},
+		IntBase:   10,
+		names:     map[string]string{columnPath(0): c.Name},
+	}
+	if err := dec.DecodeRow(1, "", strct); err != nil {
+		return nil, err
+	}
+	if val, valid, _ := nullFields(strct.Field(0)); valid.Bool() {
+		return val.Interface(), nil
+	}
+	return nil, nil
+}
+
+func columnPath(i int) string {
+	return "c" + strconv.Itoa(i)
+}
+
+// comma returns the rune of Delimiter.
+func (s *Schema) comma() (rune, error) {
+	comma := []rune(s.Delimiter)
+	if len(comma) != 1 {
+		return 0, fmt.Errorf("csv: invalid delimiter %q", s.Delimiter)
+	}
+	return comma[0], nil
+}
+
+// Decode reads b and returns one Record per row. Values that do not convert
+// to their column type and missing values in columns that are not nullable
+// are reported as a *RowError naming the column.
+func (s *Schema) Decode(b []byte, opts ...DecoderOption) ([]Record, error) {
+	typ, err := s.structType()
+	if err != nil {
+		return nil, err
+	}
+	if s.Delimiter != "" {
+		comma, err := s.comma()
+		if err != nil {
+			return nil, err
+		}
+		opts = append([]DecoderOption{ParseDelimiter(comma)}, opts...)
+	}
+	dec, err := NewCSVDecoder(b, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(map[string]int, len(s.Columns))
+	dec.names = make(map[string]string, len(s.Columns))
+	if s.NoHeader {
+		// stand in an empty header so the first row is decoded as data
+		dec.Rows = append([][]string{nil}, dec.Rows...)
+		dec.lines = append([]int{0}, dec.lines...)
+	}
+	for i, col := range s.Columns {
+		idx, ok := dec.HeaderMap[col.Name]
+		if s.NoHeader {
+			idx, ok = i, true
+		}
+		if !ok {
+			if !col.Nullable {
+				return nil, fmt.Errorf("csv: missing column %q", col.Name)
+			}
+			continue
+		}
+		header[columnPath(i)] = idx
+		dec.names[columnPath(i)] = col.Name
+	}
+	dec.HeaderMap = header
+
+	validate := dec.Validate
+	dec.Validate = func(row int, v interface{}) error {
+		strct := reflect.ValueOf(v).Elem()
+		for i, col := range s.Columns {
+			if _, valid, _ := nullFields(strct.Field(i)); !col.Nullable && !valid.Bool() {
+				return fmt.Errorf("column %q: value is required", col.Name)
+			}
+		}
+		if validate != nil {
+			return validate(row, v)
+		}
+		return nil
+	}
+
+	rows := reflect.New(reflect.SliceOf(typ))
+	if err := dec.Decode(rows.Interface()); err != nil {
+		return nil, err
+	}
+
+	rows = rows.Elem()
+	records := make([]Record, rows.Len())
+	for n := range records {
+		rec := make(Record, len(s.Columns))
+		for i := range rec {
+			if val, valid, _ := nullFields(rows.Index(n).Field(i)); valid.Bool() {
+				rec[i] = val.Interface()
+			}
+		}
+		records[n] = rec
+	}
+	return records, nil
+}
+
+// DecodeMaps is like Decode but returns each row as a map keyed by column
+// name.
+func (s *Schema) DecodeMaps(b []byte, opts ...DecoderOption) ([]map[string]interface{}, error) {
+	records, err := s.Decode(b, opts...)
+	if err != nil {
+		return nil, err
+	}
+	maps := make([]map[string]interface{}, len(records))
+	for n, rec := range records {
+		maps[n] = s.Map(rec)
+	}
+	return maps, nil
+}
+
+// Map returns rec keyed by column name.
+func (s *Schema) Map(rec Record) map[string]interface{} {
+	m := make(map[string]interface{}, len(s.Columns))
+	for i, col := range s.Columns {
+		if i < len(rec) {
+			m[col.Name] = rec[i]
+		}
+	}
+	return m
+}
+
+// Record returns the values of m in schema column order. Columns missing
+// from m are nil.
+func (s *Schema) Record(m map[string]interface{}) Record {
+	rec := make(Record, len(s.Columns))
+	for i, col := range s.Columns {
+		rec[i] = m[col.Name]
+	}
+	return rec
+}
+
+// Encode writes records with a header of the schema's column names, or
+// without a header when NoHeader is set, separated by Delimiter. Values may
+// be of any Go type convertible to the column type.
+func (s *Schema) Encode(records []Record, opts ...EncoderOption) ([]byte, error) {
+	typ, err := s.structType()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := reflect.MakeSlice(reflect.SliceOf(typ), len(records), len(records))
+	for n, rec := range records {
+		if len(rec) != len(s.Columns) {
+			return nil, &RowError{Row: n + 1, Err: fmt.Errorf("record has %d values, want %d", len(rec), len(s.Columns))}
+		}
+		for i, col := range s.Columns {
+			if err := col.set(rows.Index(n).Field(i), rec[i]); err != nil {
+				return nil, &RowError{Row: n + 1, Err: err}
+			}
+		}
+	}
+
+	labels := make(map[string]string, len(s.Columns))
+	for i, col := range s.Columns {
+		labels[columnPath(i)] = col.Name
+	}
+	opts = append(opts, LabelHeaders(func(path string) string {
+		return labels[path]
+	}))
+	if s.Delimiter != "" {
+		comma, err := s.comma()
+		if err != nil {
+			return nil, err
+		}
+		opts = append([]EncoderOption{FormatDelimiter(comma)}, opts...)
+	}
+
+	encoder, err := NewCSVEncoder(rows, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if s.NoHeader {
+		encoder.Rows = encoder.Rows[:0]
+	}
+	return encoder.Encode(rows)
+}
+
+// EncodeMaps is like Encode for rows keyed by column name.
+func (s *Schema) EncodeMaps(maps []map[string]interface{}, opts ...EncoderOption) ([]byte, error) {
+	records := make([]Record, len(maps))
+	for n, m := range maps {
+		records[n] = s.Record(m)
+	}
+	return s.Encode(records, opts...)
+}
+
+// set stores v in the nullable field of column c. Numbers must convert
+// without loss: a negative or fractional value does not fit an integer
+// column of its sign, nor does one out of the column type's range.
+func (c Column) set(fld reflect.Value, v interface{}) error {
+	val, valid, _ := nullFields(fld)
+	if v == nil {
+		if !c.Nullable {
+			return fmt.Errorf("column %q: value is required", c.Name)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	ok := false
+	switch c.Type {
+	case TypeString:
+		ok = rv.Kind() == reflect.String
+	case TypeInt, TypeUint, TypeFloat:
+		if !isNumber(rv.Kind()) {
+			break
+		}
+		if err := c.checkNumber(rv, val.Type()); err != nil {
+			return err
+		}
+		ok = true
+	case TypeBool:
+		ok = rv.Kind() == reflect.Bool
+	case TypeTime:
+		ok = rv.Type() == timeType
+	}
+	if !ok {
+		return fmt.Errorf("column %q: cannot use %T as %s", c.Name, v, c.Type)
+	}
+
+	val.Set(rv.Convert(val.Type()))
+	valid.SetBool(true)
+	return nil
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// checkNumber reports whether the number rv converts to t, the value type
+// of column c, without loss.
+func (c Column) checkNumber(rv reflect.Value, t reflect.Type) error {
+	rangeErr := &RangeError{Column: c.Name, Value: fmt.Sprint(rv.Interface()), Type: t}
+	switch t.Kind() {
+	case reflect.Int64:
+		switch {
+		case rv.CanUint() && rv.Uint() > math.MaxInt64:
+			return rangeErr
+		case rv.CanFloat():
+			f := rv.Float()
+			if f != math.Trunc(f) {
+				return fmt.Errorf("column %q: %v is not an integer", c.Name, rv.Interface())
+			}
+			if f < math.MinInt64 || f >= math.MaxInt64 {
+				return rangeErr
+			}
+		}
+	case reflect.Uint64:
+		switch {
+		case rv.CanInt() && rv.Int() < 0:
+			return rangeErr
+		case rv.CanFloat():
+			f := rv.Float()
+			if f != math.Trunc(f) {
+				return fmt.Errorf("column %q: %v is not an integer", c.Name, rv.Interface())
+			}
+			if f < 0 || f >= math.MaxUint64 {
+				return rangeErr
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package csv
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var people = Schema{Columns: []Column{
+	{Name: "name", Type: TypeString},
+	{Name: "age", Type: TypeUint},
+	{Name: "score, avg", Type: TypeFloat, Nullable: true},
+	{Name: "active", Type: TypeBool, Format: "Y/N", Nullable: true},
+}}
+
+func TestSchemaDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema Schema
+		in     string
+		want   []Record
+		err    string
+	}{
+		{
+			name:   "nullable",
+			schema: people,
+			in:     "name,age,\"score, avg\",active\nann,30,,Y\nbob,41,2.5,\n",
+			want: []Record{
+				{"ann", uint64(30), nil, true},
+				{"bob", uint64(41), 2.5, nil},
+			},
+		},
+		{
+			name:   "missing nullable column",
+			schema: people,
+			in:     "name,age\nann,30\n",
+			want:   []Record{{"ann", uint64(30), nil, nil}},
+		},
+		{
+			name:   "missing required column",
+			schema: people,
+			in:     "name,active\nann,Y\n",
+			err:    `csv: missing column "age"`,
+		},
+		{
+			name:   "required value",
+			schema: people,
+			in:     "name,age\nann,\n",
+			err:    `csv: row 1 (line 2): column "age": value is required`,
+		},
+		{
+			name: "no header",
+			schema: Schema{NoHeader: true, Columns: []Column{
+				{Name: "id", Type: TypeInt},
+				{Name: "label", Type: TypeString},
+			}},
+			in:   "1,one\n-2,two\n",
+			want: []Record{{int64(1), "one"}, {int64(-2), "two"}},
+		},
+		{
+			name: "delimiter",
+			schema: Schema{Delimiter: ";", Columns: []Column{
+				{Name: "a,b", Type: TypeString},
+				{Name: "n", Type: TypeFloat, Format: "de"},
+			}},
+			in:   "a,b;n\nx,y;\"1.234,5\"\n",
+			want: []Record{{"x,y", 1234.5}},
+		},
+		{
+			name:   "invalid delimiter",
+			schema: Schema{Delimiter: ";;", Columns: people.Columns},
+			in:     "name\n",
+			err:    `csv: invalid delimiter ";;"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schema.Decode([]byte(tt.in))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Decode error = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaDecodeErrors(t *testing.T) {
+	tests := []struct {
+		in     string
+		row    int
+		column string
+	}{
+		{"name,age\nann,30\nbob,x\n", 2, "age"},
+		{"name,age\nann,-1\n", 1, "age"},
+		{"name,age,\"score, avg\"\nann,1,1e999\n", 1, "score, avg"},
+		{"name,age,active\nann,1,yes\n", 1, "active"},
+	}
+	for _, tt := range tests {
+		_, err := people.Decode([]byte(tt.in))
+		var re *RowError
+		if !errors.As(err, &re) {
+			t.Errorf("%q: error = %v, want a *RowError", tt.in, err)
+			continue
+		}
+		if re.Row != tt.row {
+			t.Errorf("%q: row = %d, want %d", tt.in, re.Row, tt.row)
+		}
+		if !strings.Contains(err.Error(), tt.column) || strings.Contains(err.Error(), "c1") {
+			t.Errorf("%q: error %q does not name column %q", tt.in, err, tt.column)
+		}
+	}
+}
+
+func TestColumnParse(t *testing.T) {
+	tests := []struct {
+		col   Column
+		cell  string
+		want  interface{}
+		err   bool
+		limit string
+	}{
+		{people.Columns[1], "41", uint64(41), false, ""},
+		{people.Columns[1], "", nil, false, ""},
+		{people.Columns[1], "-1", nil, true, ""},
+		{people.Columns[3], "Y", true, false, ""},
+		{people.Columns[3], "yes", nil, true, ""},
+		{Column{Name: "n", Type: TypeInt, Format: "de", Max: "1000"}, "1.000", int64(1000), false, ""},
+		{Column{Name: "n", Type: TypeInt, Format: "de", Max: "1000"}, "1.001", nil, true, "1000"},
+		{Column{Name: "b", Type: TypeBool, Format: "Y"}, "Y", nil, true, ""},
+		{Column{Name: "x", Type: "date"}, "1", nil, true, ""},
+	}
+	for _, tt := range tests {
+		got, err := tt.col.Parse(tt.cell)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%+v.Parse(%q) = %v, %v, want %v", tt.col, tt.cell, got, err, tt.want)
+		}
+		var re *RangeError
+		if tt.limit != "" && (!errors.As(err, &re) || re.Column != tt.col.Name || re.Max != tt.limit) {
+			t.Errorf("%+v.Parse(%q): error = %v, want a range error", tt.col, tt.cell, err)
+		}
+	}
+}
+
+func TestSchemaEncode(t *testing.T) {
+	b, err := people.Encode([]Record{
+		{"ann", 30, nil, true},
+		{"bob", uint8(41), float32(2.5), nil},
+		{"cy", 7.0, 1, false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "name,age,\"score, avg\",active\nann,30,,Y\nbob,41,2.5,\ncy,7,1,N"
+	if string(b) != want {
+		t.Errorf("Encode =\n%s\nwant\n%s", b, want)
+	}
+}
+
+func TestSchemaEncodeLossy(t *testing.T) {
+	ints := Schema{Columns: []Column{
+		{Name: "i", Type: TypeInt, Nullable: true},
+		{Name: "u", Type: TypeUint, Nullable: true},
+	}}
+	// err is the message, or the column of a *RangeError
+	tests := []struct {
+		rec     Record
+		err     string
+		isRange bool
+	}{
+		{rec: Record{nil, -1}, err: "u", isRange: true},
+		{rec: Record{nil, -0.5}, err: `column "u": -0.5 is not an integer`},
+		{rec: Record{1.5, nil}, err: `column "i": 1.5 is not an integer`},
+		{rec: Record{math.NaN(), nil}, err: `column "i": NaN is not an integer`},
+		{rec: Record{uint64(math.MaxUint64), nil}, err: "i", isRange: true},
+		{rec: Record{math.Pow(2, 63), nil}, err: "i", isRange: true},
+		{rec: Record{nil, math.Pow(2, 64)}, err: "u", isRange: true},
+		{rec: Record{math.Inf(-1), nil}, err: "i", isRange: true},
+		{rec: Record{"1", nil}, err: `column "i": cannot use string as int`},
+	}
+	for _, tt := range tests {
+		_, err := ints.Encode([]Record{{int64(0), uint64(0)}, tt.rec})
+		var re *RowError
+		if !errors.As(err, &re) || re.Row != 2 {
+			t.Errorf("%v: error = %v, want a *RowError for row 2", tt.rec, err)
+			continue
+		}
+		var rangeErr *RangeError
+		if tt.isRange {
+			if !errors.As(err, &rangeErr) || rangeErr.Column != tt.err {
+				t.Errorf("%v: error = %v, want a *RangeError for column %s", tt.rec, err, tt.err)
+			}
+		} else if errors.As(err, &rangeErr) || !strings.HasSuffix(err.Error(), tt.err) {
+			t.Errorf("%v: error = %v, want %s", tt.rec, err, tt.err)
+		}
+	}
+
+	// the extremes that do fit
+	if _, err := ints.Encode([]Record{{math.MinInt64, uint64(math.MaxUint64)}, {-math.Pow(2, 63), 1e19}}); err != nil {
+		t.Errorf("Encode: %v", err)
+	}
+
+	if _, err := people.Encode([]Record{{"ann", nil, nil, nil}}); err == nil || !strings.Contains(err.Error(), `column "age": value is required`) {
+		t.Errorf("Encode nil required value: error = %v", err)
+	}
+}
+
+func TestSchemaEncodeLayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema Schema
+		want   string
+	}{
+		{"delimiter", Schema{Delimiter: ";", Columns: people.Columns}, "name;age;score, avg;active\n\"a;b\";30;1.5;Y"},
+		{"no header", Schema{NoHeader: true, Columns: people.Columns}, "a;b,30,1.5,Y"},
+		{"both", Schema{Delimiter: "\t", NoHeader: true, Columns: people.Columns}, "a;b\t30\t1.5\tY"},
+	}
+	in := []Record{{"a;b", uint64(30), 1.5, true}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.schema.Encode(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("Encode = %q, want %q", b, tt.want)
+			}
+			out, err := tt.schema.Decode(b)
+			if err != nil {
+				t.Fatalf("Decode(%q): %v", b, err)
+			}
+			if !reflect.DeepEqual(out, in) {
+				t.Errorf("Decode(%q) = %v, want %v", b, out, in)
+			}
+		})
+	}
+
+	if _, err := (&Schema{Delimiter: "\n", Columns: people.Columns}).Encode(in); err == nil {
+		t.Error("expected an error for a newline delimiter")
+	}
+}
+
+func TestSchemaMapsRoundTrip(t *testing.T) {
+	in := []map[string]interface{}{
+		{"name": "ann", "age": uint64(30), "score, avg": 1.25, "active": true},
+		{"name": "bob", "age": uint64(0), "score, avg": nil, "active": nil},
+	}
+	b, err := people.EncodeMaps(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := people.DecodeMaps(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("DecodeMaps = %v, want %v\n%s", out, in, b)
+	}
+}
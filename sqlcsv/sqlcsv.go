@@ -0,0 +1,298 @@
+// Package sqlcsv moves CSV data in and out of databases through
+// database/sql. WriteRows streams query results to CSV and Loader inserts
+// the rows of a CSVDecoder into a table in batches.
+package sqlcsv
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xiphoid24/csv"
+	"github.com/xiphoid24/csv/internal/shared"
+)
+
+// WriteRows writes the column names of rows as a header, followed by one
+// line per result row, and returns the number of rows written. Values are
+// formatted with the encoder rules of package csv and opts; NULL is
+// written as the null token. The delimiter, gzip and formula escaping
+// options apply as they do to Marshal, while column ordering, selection
+// and label options are rejected. WriteRows does not close rows.
+func WriteRows(w io.Writer, rows *sql.Rows, opts ...csv.EncoderOption) (int, error) {
+	enc, err := csv.NewValueEncoder(opts...)
+	if err != nil {
+		return 0, err
+	}
+	if !enc.Gzip {
+		return writeRows(w, rows, enc)
+	}
+	zw := gzip.NewWriter(w)
+	n, err := writeRows(zw, rows, enc)
+	if err != nil {
+		return n, err
+	}
+	return n, zw.Close()
+}
+
+func writeRows(w io.Writer, rows *sql.Rows, enc *csv.CSVEncoder) (int, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriter(w)
+	if err := writeRecord(bw, cols, enc.Comma); err != nil {
+		return 0, err
+	}
+
+	vals := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range vals {
+		dest[i] = &vals[i]
+	}
+	cells := make([]string, len(cols))
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		for i, v := range vals {
+			if cells[i], err = enc.FormatValue(v); err != nil {
+				return n, fmt.Errorf("sqlcsv: row %d: %s: %v", n+1, cols[i], err)
+			}
+		}
+		if err := writeRecord(bw, cells, enc.Comma); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// writeRecord writes one record and its line ending.
+func writeRecord(bw *bufio.Writer, cells []string, comma rune) error {
+	if _, err := bw.Write(shared.JoinRow(cells, comma)); err != nil {
+		return err
+	}
+	return bw.WriteByte('\n')
+}
+
+// Execer runs a statement. *sql.DB, *sql.Tx and *sql.Conn implement it.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Placeholder returns the bind parameter for the n-th argument of a
+// statement, counting from one.
+type Placeholder func(n int) string
+
+var (
+	// Question writes ? parameters, as used by MySQL and SQLite.
+	Question Placeholder = func(int) string { return "?" }
+	// Dollar writes $1, $2, ... parameters, as used by PostgreSQL.
+	Dollar Placeholder = func(n int) string { return "$" + strconv.Itoa(n) }
+)
+
+// QuoteIdent quotes a table or column name with double quotes, doubling
+// any quote inside it.
+func QuoteIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// Loader inserts CSV rows into a table with multi-row parameterized INSERT
+// statements.
+type Loader struct {
+	Table string
+
+	// Columns maps CSV headers to table columns. Only mapped headers are
+	// loaded. When it is nil every header is loaded into the column of the
+	// same name.
+	Columns map[string]string
+
+	// BatchSize is the number of rows per INSERT. It defaults to 100.
+	BatchSize int
+
+	// MaxParams is the largest number of bind parameters in one INSERT,
+	// which shrinks batches of wide rows. It defaults to 999, the lowest
+	// limit of the common databases.
+	MaxParams int
+
+	// Placeholder writes bind parameters. It defaults to Question.
+	Placeholder Placeholder
+
+	// Quote quotes the table and column names. It defaults to QuoteIdent.
+	Quote func(name string) string
+}
+
+// Load inserts the data rows of dec and returns the number of rows
+// inserted. Cells the decoder treats as null, empty cells unless null
+// tokens are set, are inserted as NULL; every other value is passed as a
+// string for the database to convert. Rows whose loaded cells are all null
+// or empty follow the decoder's EmptyRows policy. Pass a *sql.Tx to load
+// all rows or none.
+func (l *Loader) Load(ctx context.Context, db Execer, dec *csv.CSVDecoder) (int64, error) {
+	header := dec.GetHeader()
+	var src []int
+	var names []string
+	if l.Columns == nil {
+		for i, h := range header {
+			src = append(src, i)
+			names = append(names, h)
+		}
+	} else {
+		for h, col := range l.Columns {
+			i, ok := dec.HeaderMap[h]
+			if !ok {
+				return 0, fmt.Errorf("sqlcsv: missing column %q", h)
+			}
+			src = append(src, i)
+			names = append(names, col)
+		}
+		// keep the file's column order, as map order is random
+		sortBy(src, names)
+	}
+	if len(src) == 0 {
+		return 0, fmt.Errorf("sqlcsv: no columns to load")
+	}
+
+	size, err := l.batchSize(len(src))
+	if err != nil {
+		return 0, err
+	}
+	// settle the empty rows first, so ErrorEmptyRows inserts nothing
+	var keep []int
+	for i := 1; i < len(dec.Rows); i++ {
+		if isEmpty(dec.Rows[i], src, dec.NullTokens) {
+			switch dec.EmptyRows {
+			case csv.SkipEmptyRows:
+				continue
+			case csv.ErrorEmptyRows:
+				return 0, &csv.RowError{Row: i, Line: dec.Line(i), Err: csv.EMPTYROW}
+			}
+		}
+		keep = append(keep, i)
+	}
+
+	var n int64
+	batch := make([][]string, 0, size)
+	for start := 0; start < len(keep); start += size {
+		end := start + size
+		if end > len(keep) {
+			end = len(keep)
+		}
+		batch = batch[:0]
+		for _, i := range keep[start:end] {
+			batch = append(batch, dec.Rows[i])
+		}
+		query, args := l.insert(names, src, batch, dec.NullTokens)
+		if _, err := db.ExecContext(ctx, query, args...); err != nil {
+			return n, fmt.Errorf("sqlcsv: rows %d-%d: %v", keep[start], keep[end-1], err)
+		}
+		n += int64(end - start)
+	}
+	return n, nil
+}
+
+// batchSize returns the number of rows per INSERT for rows of width
+// parameters.
+func (l *Loader) batchSize(width int) (int, error) {
+	size, max := l.BatchSize, l.MaxParams
+	if size <= 0 {
+		size = 100
+	}
+	if max <= 0 {
+		max = 999
+	}
+	if width > max {
+		return 0, fmt.Errorf("sqlcsv: %d columns exceed MaxParams of %d", width, max)
+	}
+	if size*width > max {
+		size = max / width
+	}
+	return size, nil
+}
+
+// isEmpty reports whether every loaded cell of row is null or empty.
+func isEmpty(row []string, src []int, nullTokens []string) bool {
+	for _, col := range src {
+		if v := cellValue(row, col, nullTokens); v != nil && v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// insert builds the statement and arguments inserting rows.
+func (l *Loader) insert(names []string, src []int, rows [][]string, nullTokens []string) (string, []interface{}) {
+	quote, param := l.Quote, l.Placeholder
+	if quote == nil {
+		quote = QuoteIdent
+	}
+	if param == nil {
+		param = Question
+	}
+
+	var b strings.Builder
+	b.WriteString("INSERT INTO " + quote(l.Table) + " (")
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(quote(name))
+	}
+	b.WriteString(") VALUES ")
+
+	args := make([]interface{}, 0, len(rows)*len(src))
+	for r, row := range rows {
+		if r > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for i, col := range src {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			args = append(args, cellValue(row, col, nullTokens))
+			b.WriteString(param(len(args)))
+		}
+		b.WriteString(")")
+	}
+	return b.String(), args
+}
+
+// cellValue returns the argument for a cell, nil for null cells.
+func cellValue(row []string, col int, nullTokens []string) interface{} {
+	if col >= len(row) {
+		return nil
+	}
+	s := row[col]
+	if len(nullTokens) == 0 {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	for _, tok := range nullTokens {
+		if s == tok {
+			return nil
+		}
+	}
+	return s
+}
+
+// sortBy sorts src ascending, moving names along with it.
+func sortBy(src []int, names []string) {
+	for i := 1; i < len(src); i++ {
+		for j := i; j > 0 && src[j] < src[j-1]; j-- {
+			src[j], src[j-1] = src[j-1], src[j]
+			names[j], names[j-1] = names[j-1], names[j]
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package sqlcsv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xiphoid24/csv"
+)
+
+// stub is a database/sql driver that answers every query with its result
+// and records every statement it executes.
+type stub struct {
+	cols   []string
+	result [][]driver.Value
+	execs  []exec
+}
+
+type exec struct {
+	query string
+	args  []driver.Value
+}
+
+var stubDriver = &stub{}
+
+func init() {
+	sql.Register("sqlcsvstub", stubDriver)
+}
+
+func (d *stub) Open(string) (driver.Conn, error) { return stubConn{d}, nil }
+
+type stubConn struct{ d *stub }
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{c.d, query}, nil }
+func (c stubConn) Close() error                              { return nil }
+func (c stubConn) Begin() (driver.Tx, error)                 { return stubTx{}, nil }
+
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
+type stubStmt struct {
+	d     *stub
+	query string
+}
+
+func (s stubStmt) Close() error  { return nil }
+func (s stubStmt) NumInput() int { return -1 }
+
+func (s stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.execs = append(s.d.execs, exec{s.query, args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &stubRows{cols: s.d.cols, rows: s.d.result}, nil
+}
+
+type stubRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string { return r.cols }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func openStub(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlcsvstub", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	stubDriver.execs = nil
+	return db
+}
+
+func TestWriteRows(t *testing.T) {
+	db := openStub(t)
+	defer db.Close()
+	stubDriver.cols = []string{"id", "name", "price", "paid", "created", "note"}
+	stubDriver.result = [][]driver.Value{
+		{int64(1), "Widget, large", 9.5, true, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), nil},
+		{int64(2), []byte(`say "hi"`), 0.25, false, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), "n"},
+	}
+
+	rows, err := db.Query("SELECT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var buf bytes.Buffer
+	n, err := WriteRows(&buf, rows, csv.FormatFloats('f', 2), csv.FormatBools("Y", "N"),
+		csv.FormatTimes("2006-01-02"), csv.FormatNulls("NULL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("wrote %d rows, want 2", n)
+	}
+	want := "id,name,price,paid,created,note\n" +
+		"1,\"Widget, large\",9.50,Y,2024-03-01,NULL\n" +
+		"2,\"say \"\"hi\"\"\",0.25,N,2024-03-02,n\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteRowsOptions(t *testing.T) {
+	db := openStub(t)
+	defer db.Close()
+	stubDriver.cols = []string{"a", "b"}
+
+	query := func() *sql.Rows {
+		stubDriver.result = [][]driver.Value{{[]byte("=1"), "x;y"}}
+		rows, err := db.Query("SELECT")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	var buf bytes.Buffer
+	rows := query()
+	_, err := WriteRows(&buf, rows, csv.FormatDelimiter(';'), csv.EscapeFormulas(), csv.GzipOutput())
+	rows.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a;b\n'=1;\"x;y\"\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, opt := range []csv.EncoderOption{csv.OrderColumns("b"), csv.SelectColumns("a"), csv.FormatDelimiter('\n')} {
+		rows := query()
+		if _, err := WriteRows(&buf, rows, opt); err == nil {
+			t.Error("expected an error for an option that does not apply")
+		}
+		rows.Close()
+	}
+}
+
+func TestLoad(t *testing.T) {
+	db := openStub(t)
+	defer db.Close()
+	dec, err := csv.NewCSVDecoder([]byte("name,skip,qty\na,x,1\nb,y,\nc,z,3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Loader{
+		Table:       "items",
+		Columns:     map[string]string{"qty": "quantity", "name": "name"},
+		BatchSize:   2,
+		Placeholder: Dollar,
+	}
+	n, err := l.Load(context.Background(), db, dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("loaded %d rows, want 3", n)
+	}
+
+	want := []exec{
+		{`INSERT INTO "items" ("name", "quantity") VALUES ($1, $2), ($3, $4)`, []driver.Value{"a", "1", "b", nil}},
+		{`INSERT INTO "items" ("name", "quantity") VALUES ($1, $2)`, []driver.Value{"c", "3"}},
+	}
+	if !reflect.DeepEqual(stubDriver.execs, want) {
+		t.Errorf("got %q, want %q", stubDriver.execs, want)
+	}
+}
+
+func TestLoadEmptyRows(t *testing.T) {
+	in := []byte("name,skip,qty\na,x,1\n,y,\nNULL,z,NULL\nc,z,3")
+	tests := []struct {
+		policy csv.EmptyRowPolicy
+		n      int64
+		line   int
+	}{
+		{csv.SkipEmptyRows, 2, 0},
+		{csv.KeepEmptyRows, 4, 0},
+		{csv.ErrorEmptyRows, 0, 3},
+	}
+	for _, tt := range tests {
+		db := openStub(t)
+		dec, err := csv.NewCSVDecoder(in, csv.WithEmptyRows(tt.policy), csv.ParseNulls("", "NULL"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := &Loader{Table: "items", Columns: map[string]string{"qty": "qty", "name": "name"}}
+		n, err := l.Load(context.Background(), db, dec)
+		db.Close()
+		if tt.line > 0 {
+			var re *csv.RowError
+			if !errors.As(err, &re) || re.Row != 2 || re.Line != tt.line || !errors.Is(err, csv.EMPTYROW) || len(stubDriver.execs) != 0 {
+				t.Errorf("policy %d: error = %v after %d statements, want an empty row error for row 2 and nothing inserted", tt.policy, err, len(stubDriver.execs))
+			}
+			continue
+		}
+		if err != nil || n != tt.n {
+			t.Errorf("policy %d: loaded %d rows, %v, want %d", tt.policy, n, err, tt.n)
+		}
+	}
+}
+
+func TestLoadMaxParams(t *testing.T) {
+	db := openStub(t)
+	defer db.Close()
+	dec, err := csv.NewCSVDecoder([]byte("a,b,c\n1,2,3\n4,5,6\n7,8,9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Loader{Table: "t", MaxParams: 7}
+	n, err := l.Load(context.Background(), db, dec)
+	if err != nil || n != 3 {
+		t.Fatalf("loaded %d rows, %v, want 3", n, err)
+	}
+	if len(stubDriver.execs) != 2 || len(stubDriver.execs[0].args) != 6 || len(stubDriver.execs[1].args) != 3 {
+		t.Errorf("got %q, want statements of 2 rows and 1 row", stubDriver.execs)
+	}
+
+	l.MaxParams = 2
+	if _, err := l.Load(context.Background(), db, dec); err == nil {
+		t.Error("expected an error for rows wider than MaxParams")
+	}
+}
+
+func TestLoadMissingColumn(t *testing.T) {
+	db := openStub(t)
+	defer db.Close()
+	dec, err := csv.NewCSVDecoder([]byte("name\na"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Loader{Table: "items", Columns: map[string]string{"qty": "quantity"}}
+	if _, err := l.Load(context.Background(), db, dec); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+}
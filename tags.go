@@ -0,0 +1,19 @@
+package csv
+
+import "github.com/xiphoid24/csv/internal/tags"
+
+// tagOptions holds the options that follow the column name in a csv struct
+// tag, e.g. `csv:"price,format=f,prec=2"`. A name or value that contains a
+// comma is wrapped in single quotes, e.g. `csv:"'Last, First'"`.
+type tagOptions = tags.Options
+
+// parseTag splits a csv struct tag into the column name and its options.
+func parseTag(tag string) (string, tagOptions) {
+	return tags.Parse(tag)
+}
+
+// quoteTag quotes a column name or option value for a csv struct tag when
+// it needs it.
+func quoteTag(s string) string {
+	return tags.Quote(s)
+}
@@ -0,0 +1,73 @@
+package csv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatOptions(t *testing.T) {
+	type row struct {
+		Price float64   `csv:"price,format=f,prec=2"`
+		Big   float64   `csv:"big"`
+		Hex   int       `csv:"hex,base=16"`
+		Bits  uint8     `csv:"bits,base=2"`
+		Flag  bool      `csv:"flag,true=Y,false=N"`
+		Other bool      `csv:"other"`
+		When  time.Time `csv:"when,layout='Jan 2, 2006'"`
+	}
+	in := []row{{1.5, 1e6, 255, 5, true, false, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}}
+
+	tests := []struct {
+		enc  []EncoderOption
+		dec  []DecoderOption
+		want string
+	}{
+		{nil, nil, "price,big,hex,bits,flag,other,when\n1.50,1e+06,ff,101,Y,false,\"Mar 1, 2024\""},
+		{
+			[]EncoderOption{FormatFloats('f', 0), FormatBools("1", "0")},
+			[]DecoderOption{ParseBools("1", "0")},
+			"price,big,hex,bits,flag,other,when\n1.50,1000000,ff,101,Y,0,\"Mar 1, 2024\"",
+		},
+	}
+	for _, tt := range tests {
+		b, err := Marshal(in, tt.enc...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("Marshal = %q, want %q", b, tt.want)
+		}
+		var out []row
+		if err := Unmarshal(b, &out, tt.dec...); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("Unmarshal = %+v, want %+v", out, in)
+		}
+	}
+}
+
+func TestInvalidBase(t *testing.T) {
+	type row struct {
+		N int `csv:"n,base=x"`
+	}
+	_, err := Marshal([]row{{1}})
+	if err == nil || !strings.Contains(err.Error(), `invalid base option "x"`) {
+		t.Errorf("Marshal: got %v", err)
+	}
+	var out []row
+	err = Unmarshal([]byte("n\n1"), &out)
+	if err == nil || !strings.Contains(err.Error(), `invalid base option "x"`) {
+		t.Errorf("Unmarshal: got %v", err)
+	}
+
+	type plain struct {
+		N int `csv:"n"`
+	}
+	_, err = Marshal([]plain{{1}}, FormatInts(40))
+	if err == nil || !strings.Contains(err.Error(), "invalid integer base 40") {
+		t.Errorf("FormatInts(40): got %v", err)
+	}
+}